@@ -0,0 +1,31 @@
+package main
+
+import "time"
+
+type ActivityStreamEntry struct {
+	ID            int                         `json:"id"`
+	Type          string                      `json:"type"`
+	Timestamp     time.Time                   `json:"timestamp,string"`
+	Operation     string                      `json:"operation"`
+	Changes       map[string]interface{}      `json:"changes"`
+	Object1       string                      `json:"object1"`
+	Object2       string                      `json:"object2"`
+	SummaryFields ActivityStreamSummaryFields `json:"summary_fields"`
+}
+
+type ActivityStreamSummaryFields struct {
+	Host  []ObjectSummary `json:"host"`
+	Group []ObjectSummary `json:"group"`
+}
+
+type ObjectSummary struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type ActivityStreamResults struct {
+	Count    int                   `json:"count"`
+	Next     string                `json:"next"`
+	Previous string                `json:"previous"`
+	Results  []ActivityStreamEntry `json:"results"`
+}
@@ -66,26 +66,29 @@ type InventoryUserCapabilities struct {
 }
 
 type Inventory struct {
-	ID                       int                    `json:"id"`
-	Type                     string                 `json:"type"`
-	Url                      string                 `json:"url"`
-	Related                  InventoryRelated       `json:"related"`
-	SummaryFields            InventorySummaryFields `json:"summary_fields"`
-	Created                  time.Time              `json:"created,string"`
-	Modified                 time.Time              `json:"modified,string"`
-	Name                     string                 `json:"name"`
-	Description              string                 `json:"description"`
-	Organization             int                    `json:"organization"`
-	Kind                     string                 `json:"kind"`
-	HostFilter               string                 `json:"host_filter"`
-	Variables                string                 `json:"variables"`
-	HasActiveFailures        bool                   `json:"has_active_failures"`
-	HostsWithActiveFailures  int                    `json:"hosts_with_active_failures"`
-	TotalGroups              int                    `json:"total_groups"`
-	GroupsWithActiveFailures int                    `json:"groups_with_active_failures"`
-	HasInventorySources      bool                   `json:"has_inventory_sources"`
-	InsightsCredential       string                 `json:"insights_credential"`
-	PendingDeletion          bool                   `json:"pending_deletion"`
+	ID                           int                    `json:"id"`
+	Type                         string                 `json:"type"`
+	Url                          string                 `json:"url"`
+	Related                      InventoryRelated       `json:"related"`
+	SummaryFields                InventorySummaryFields `json:"summary_fields"`
+	Created                      time.Time              `json:"created,string"`
+	Modified                     time.Time              `json:"modified,string"`
+	Name                         string                 `json:"name"`
+	Description                  string                 `json:"description"`
+	Organization                 int                    `json:"organization"`
+	Kind                         string                 `json:"kind"`
+	HostFilter                   string                 `json:"host_filter"`
+	Variables                    string                 `json:"variables"`
+	HasActiveFailures            bool                   `json:"has_active_failures"`
+	TotalHosts                   int                    `json:"total_hosts"`
+	HostsWithActiveFailures      int                    `json:"hosts_with_active_failures"`
+	TotalGroups                  int                    `json:"total_groups"`
+	GroupsWithActiveFailures     int                    `json:"groups_with_active_failures"`
+	HasInventorySources          bool                   `json:"has_inventory_sources"`
+	TotalInventorySources        int                    `json:"total_inventory_sources"`
+	InventorySourcesWithFailures int                    `json:"inventory_sources_with_failures"`
+	InsightsCredential           string                 `json:"insights_credential"`
+	PendingDeletion              bool                   `json:"pending_deletion"`
 }
 
 type InventoryResult struct {
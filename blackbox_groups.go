@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+/// The strategies that select the group label of the blackbox host entries
+const (
+	groupStrategyFirst    = "first"
+	groupStrategyPriority = "priority"
+	groupStrategyAll      = "all"
+)
+
+/// groupRegexps caches the compiled regular expressions of the group patterns
+var groupRegexps sync.Map
+
+/// compileGroupPattern Returns the regular expression of a /regex/ pattern or nil for a glob pattern
+func compileGroupPattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) < 2 || strings.HasPrefix(pattern, "/") == false || strings.HasSuffix(pattern, "/") == false {
+		return nil, nil
+	}
+	if cached, ok := groupRegexps.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(pattern[1 : len(pattern)-1])
+	if err != nil {
+		return nil, err
+	}
+	groupRegexps.Store(pattern, compiled)
+	return compiled, nil
+}
+
+/// matchGroupPattern Checks if the group name matches the pattern, which is a glob like sles* or a
+/// regular expression between slashes like /^sles[0-9]+/
+func matchGroupPattern(pattern string, name string) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+	compiled, err := compileGroupPattern(pattern)
+	if err != nil {
+		return false
+	}
+	if compiled != nil {
+		return compiled.MatchString(name)
+	}
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
+
+/// matchAnyGroupPattern Checks if the group name matches one of the patterns
+func matchAnyGroupPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchGroupPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/// validateGroupPatterns Returns an error for the first invalid glob or regular expression of the patterns
+func validateGroupPatterns(key string, patterns []string) error {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		compiled, err := compileGroupPattern(pattern)
+		if err != nil {
+			return fmt.Errorf("The pattern %s of %s is not a valid regular expression: %v", pattern, key, err)
+		}
+		if compiled != nil {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("The pattern %s of %s is not a valid glob: %v", pattern, key, err)
+		}
+	}
+	return nil
+}
+
+/// selectBlackboxGroups Returns the groups the host entries of the blackbox config are created for.
+/// The ignored groups are skipped, the strategy first takes the first of the other groups, priority
+/// the first group that matches the preferred groups in their order and all takes all of them.
+/// Without any group the fallback group is used when it is set.
+func selectBlackboxGroups(config Config, groups []string) []string {
+	var candidates []string
+	for _, group := range groups {
+		if matchAnyGroupPattern(config.blackbox.IgnoredGroups, group) == false {
+			candidates = append(candidates, group)
+		}
+	}
+	if len(candidates) == 0 {
+		if config.blackbox.fallbackGroup != "" {
+			return []string{config.blackbox.fallbackGroup}
+		}
+		return nil
+	}
+	switch config.blackbox.groupStrategy {
+	case groupStrategyAll:
+		return candidates
+	case groupStrategyPriority:
+		for _, pattern := range config.blackbox.preferredGroups {
+			for _, candidate := range candidates {
+				if matchGroupPattern(pattern, candidate) {
+					return []string{candidate}
+				}
+			}
+		}
+	}
+	return candidates[:1]
+}
+
+/// getAllHostGroups Returns all the groups of the host by following the pages of the given path
+func getAllHostGroups(config Config, path string, groups []Group) ([]Group, error) {
+	var results GroupResults
+	err := getAWXResults(config, path, true, &results)
+	if err != nil {
+		return groups, err
+	}
+	groups = append(groups, results.Results...)
+	if results.Next == "" {
+		return groups, nil
+	}
+	return getAllHostGroups(config, results.Next, groups)
+}
+
+/// getHostGroupNames Returns the names of the groups the host is a direct member of, in the order of the groups
+/// of the inventory model. The summary of the host contains only the first groups, so the complete list is
+/// fetched when it is truncated.
+func getHostGroupNames(config Config, host Host) ([]string, error) {
+	var groups []Group
+	summaryGroups := host.SummaryFields.Groups
+	if summaryGroups.Count <= len(summaryGroups.Results) || host.Related.Groups == "" {
+		for _, group := range summaryGroups.Results {
+			groups = append(groups, Group{ID: group.ID, Name: group.Name})
+		}
+	} else {
+		var err error
+		groups, err = getAllHostGroups(config, host.Related.Groups, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortGroups(groups)
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names, nil
+}
+
+/// getModelGroupNames Returns the names of the given groups of the inventory model
+func getModelGroupNames(groups []*ModelGroup) []string {
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Group.Name)
+	}
+	return names
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+/// BlackboxFileConfig is the content of a blackbox.yml, the keys that are not managed are kept as they are
+type BlackboxFileConfig yaml.MapSlice
+
+/// BlackboxModule is a module of the blackbox exporter defined in the AWX group variables
+type BlackboxModule struct {
+	Name       string
+	Group      string
+	Definition interface{}
+}
+
+/// String Returns the yaml representation of the blackbox config
+func (blackboxFileConfig BlackboxFileConfig) String() string {
+	out, err := yaml.Marshal(yaml.MapSlice(blackboxFileConfig))
+	if err != nil {
+		return fmt.Sprintf("<error creating blackbox config string: %s>", err)
+	}
+	return string(out)
+}
+
+/// readBlackboxConfig Reads the blackbox exporter configuration from the modules source file
+func readBlackboxConfig(config Config) (BlackboxFileConfig, error) {
+	var blackboxFileConfig yaml.MapSlice
+	content, err := ioutil.ReadFile(config.blackbox.modulesSourceFile)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(content, &blackboxFileConfig)
+	if err != nil {
+		return nil, err
+	}
+	return BlackboxFileConfig(blackboxFileConfig), nil
+}
+
+/// createBlackboxModules Creates the modules of the given group variable, which should be a map of the
+/// module names to their definition. A module that is already defined by another group with a different
+/// definition is skipped.
+func createBlackboxModules(config Config, group string, modulesConfig interface{}, modules []BlackboxModule) []BlackboxModule {
+	modulesMap, ok := modulesConfig.(map[string]interface{})
+	if !ok {
+		recordValidationError(config.blackbox.modulesConfigName, fmt.Sprintf("the modules of the group %s should be a map", group))
+		return modules
+	}
+	var names []string
+	for name := range modulesMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		definition, ok := modulesMap[name].(map[string]interface{})
+		if !ok {
+			recordValidationError(config.blackbox.modulesConfigName, fmt.Sprintf("the module %s of the group %s should be a map", name, group))
+			continue
+		}
+		if definition["prober"] == nil {
+			recordValidationError(config.blackbox.modulesConfigName, fmt.Sprintf("the module %s of the group %s has no prober", name, group))
+			continue
+		}
+		duplicate := false
+		for _, module := range modules {
+			if module.Name != name {
+				continue
+			}
+			duplicate = true
+			if reflect.DeepEqual(module.Definition, modulesMap[name]) == false {
+				recordValidationError(config.blackbox.modulesConfigName, fmt.Sprintf("the module %s of the group %s is already defined by the group %s", name, group, module.Group))
+			}
+		}
+		if duplicate == false {
+			modules = append(modules, BlackboxModule{Name: name, Group: group, Definition: modulesMap[name]})
+		}
+	}
+	return modules
+}
+
+/// getBlackboxModules Returns the modules of all the AWX groups with the modules variable
+func getBlackboxModules(config Config) ([]BlackboxModule, error) {
+	var modules []BlackboxModule
+	groups, err := getAllGroups(config, "variables__icontains="+url.QueryEscape(config.blackbox.modulesConfigName), nil)
+	if err != nil {
+		return modules, err
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	for _, group := range groups {
+		groupVariables, err := getGroupVariables(config, group)
+		if err != nil {
+			return modules, err
+		}
+		if modulesConfig, ok := groupVariables[config.blackbox.modulesConfigName]; ok {
+			modules = createBlackboxModules(config, group.Name, modulesConfig, modules)
+		}
+	}
+	return modules, nil
+}
+
+/// mergeBlackboxModules Merges the given modules in the modules of the blackbox config. The existing modules
+/// are updated, the new ones are added and the modules that are only in the file are kept as they are.
+func mergeBlackboxModules(blackboxFileConfig BlackboxFileConfig, modules []BlackboxModule) (BlackboxFileConfig, error) {
+	modulesIndex := -1
+	for idx, item := range blackboxFileConfig {
+		if item.Key == "modules" {
+			modulesIndex = idx
+		}
+	}
+	if modulesIndex == -1 {
+		blackboxFileConfig = append(blackboxFileConfig, yaml.MapItem{Key: "modules"})
+		modulesIndex = len(blackboxFileConfig) - 1
+	}
+	var existing yaml.MapSlice
+	if value := blackboxFileConfig[modulesIndex].Value; value != nil {
+		var ok bool
+		existing, ok = value.(yaml.MapSlice)
+		if !ok {
+			return nil, errors.New("the modules of the blackbox config should be a map")
+		}
+	}
+	merged := append(yaml.MapSlice{}, existing...)
+	for _, module := range modules {
+		found := false
+		for idx, item := range merged {
+			if fmt.Sprintf("%v", item.Key) == module.Name {
+				merged[idx].Value = module.Definition
+				found = true
+			}
+		}
+		if found == false {
+			merged = append(merged, yaml.MapItem{Key: module.Name, Value: module.Definition})
+		}
+	}
+	blackboxFileConfig[modulesIndex].Value = merged
+	return blackboxFileConfig, nil
+}
+
+/// checkBlackboxModules Returns an error when the blackbox hosts reference modules that are not defined
+func checkBlackboxModules(blackboxFileConfig BlackboxFileConfig, blackboxHosts []BlackboxHost) error {
+	defined := make(map[string]bool)
+	for _, item := range blackboxFileConfig {
+		if item.Key != "modules" {
+			continue
+		}
+		if modules, ok := item.Value.(yaml.MapSlice); ok {
+			for _, module := range modules {
+				defined[fmt.Sprintf("%v", module.Key)] = true
+			}
+		}
+	}
+	var undefined []string
+	for _, blackboxHost := range blackboxHosts {
+		module := blackboxHost.Labels.Module
+		if defined[module] == false && inSlice(module, undefined) == false {
+			undefined = append(undefined, module)
+		}
+	}
+	if len(undefined) > 0 {
+		sort.Strings(undefined)
+		return fmt.Errorf("the modules %s are used by the blackbox targets but not defined in the blackbox config", strings.Join(undefined, ", "))
+	}
+	return nil
+}
+
+/// loadBlackboxFileConfig Returns the modules source file with the modules of the AWX groups merged in
+func loadBlackboxFileConfig(config Config, source InventorySource) (BlackboxFileConfig, error) {
+	blackboxFileConfig, err := readBlackboxConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("can not read the blackbox config: %w", err)
+	}
+	modules, err := source.BlackboxModules(config)
+	if err != nil {
+		return nil, err
+	}
+	blackboxFileConfig, err = mergeBlackboxModules(blackboxFileConfig, modules)
+	if err != nil {
+		return nil, fmt.Errorf("can not merge the blackbox modules: %w", err)
+	}
+	return blackboxFileConfig, nil
+}
+
+/// createBlackboxFileConfig Creates the blackbox exporter configuration from the modules source file and
+/// the modules of the AWX groups, fails when a blackbox target uses a module that is not defined
+func createBlackboxFileConfig(config Config, source InventorySource) (BlackboxFileConfig, error) {
+	blackboxFileConfig, err := loadBlackboxFileConfig(config, source)
+	if err != nil {
+		return nil, err
+	}
+	blackboxHosts, err := source.BlackboxHosts(config)
+	if err != nil {
+		return nil, err
+	}
+	err = checkBlackboxModules(blackboxFileConfig, blackboxHosts)
+	if err != nil {
+		return nil, err
+	}
+	return blackboxFileConfig, nil
+}
+
+/// createBlackboxFileConfigOutput Creates the printable blackbox exporter config
+func createBlackboxFileConfigOutput(config Config, source InventorySource) ([]byte, error) {
+	blackboxFileConfig, err := createBlackboxFileConfig(config, source)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(blackboxFileConfig.String()), nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/// getProberNames Returns the probers of the given value, which is a list of prober names, a single prober
+/// name or all for every prober of the registry. The unknown probers are skipped.
+func getProberNames(config Config, probersValue interface{}) []string {
+	if name, ok := probersValue.(string); ok && name != "all" {
+		probersValue = []interface{}{name}
+	}
+	if fmt.Sprintf("%v", probersValue) == "all" {
+		var names []string
+		for name := range config.blackbox.probers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	list, ok := probersValue.([]interface{})
+	if !ok {
+		recordValidationError(config.blackbox.configName, "the probers should be a list or all")
+		return nil
+	}
+	var names []string
+	for _, item := range list {
+		name, ok := getProberName(config, fmt.Sprintf("%v", item))
+		if !ok {
+			recordValidationError(config.blackbox.configName, fmt.Sprintf("the prober %v is not defined in PROBERS", item))
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+/// getProberName Returns the name of the prober as it is defined in PROBERS, the prober names are case
+/// insensitive
+func getProberName(config Config, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	for proberName := range config.blackbox.probers {
+		if strings.EqualFold(proberName, name) {
+			return proberName, true
+		}
+	}
+	return "", false
+}
+
+/// getEntryProbers Returns the probers of the blackbox entry, which are its own probers, the default probers
+/// of the group or the DefaultProbers in this order
+func getEntryProbers(config Config, entry map[string]interface{}, groupProbers interface{}) []string {
+	if probers, ok := entry["probers"]; ok {
+		return getProberNames(config, probers)
+	}
+	if groupProbers != nil {
+		return getProberNames(config, groupProbers)
+	}
+	var names []string
+	for _, name := range config.blackbox.defaultProbers {
+		if proberName, ok := getProberName(config, name); ok {
+			names = append(names, proberName)
+		}
+	}
+	return names
+}
+
+/// getGroupProbers Returns the default probers of the given group or nil
+func getGroupProbers(groupConfigs []BlackboxGroupConfig, group string) interface{} {
+	for _, groupConfig := range groupConfigs {
+		if groupConfig.Group == group && groupConfig.Probers != nil {
+			return groupConfig.Probers
+		}
+	}
+	return nil
+}
+
+/// expandBlackboxProbers Returns the blackbox host once for every prober with the prober labels set.
+/// Without probers the host is probed by the ExporterAddress and returned as it is.
+func expandBlackboxProbers(config Config, blackboxHost BlackboxHost, probers []string) []BlackboxHost {
+	if len(probers) == 0 {
+		return []BlackboxHost{blackboxHost}
+	}
+	var blackboxHosts []BlackboxHost
+	for _, prober := range probers {
+		proberHost := blackboxHost
+		proberHost.Labels.Prober = prober
+		proberHost.Labels.ProberAddress = config.blackbox.probers[prober]
+		blackboxHosts = append(blackboxHosts, proberHost)
+	}
+	return blackboxHosts
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+/// labelNameRegexp matches the valid prometheus label names
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+/// reservedBlackboxLabels are the labels that are set by the exporter and can not be set by a target
+var reservedBlackboxLabels = []string{"group", "host", "ip", "job", "module", "prober", "instance"}
+
+/// getMap Returns the labels of the blackbox host as map, the extra labels of the target objects included
+func (labels BlackboxHostLabel) getMap() map[string]string {
+	labelMap := map[string]string{
+		"group":  labels.Group,
+		"host":   labels.Host,
+		"ip":     labels.IP,
+		"job":    labels.Job,
+		"module": labels.Module,
+	}
+	if labels.Prober != "" {
+		labelMap["prober"] = labels.Prober
+		labelMap["__prober_address"] = labels.ProberAddress
+	}
+	for name, value := range labels.Extra {
+		labelMap[name] = value
+	}
+	return labelMap
+}
+
+/// MarshalJSON Returns the labels of the blackbox host as flat json object
+func (labels BlackboxHostLabel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(labels.getMap())
+}
+
+/// createTargetBlackboxHost Creates the blackbox host of a target object with the url, module, labels and
+/// interval keys on top of the labels of its entry. Returns false when the target is invalid.
+func createTargetBlackboxHost(
+	config Config,
+	entryLabels BlackboxHostLabel,
+	hostVariables map[string]interface{},
+	target map[string]interface{}) (BlackboxHost, bool) {
+	blackboxHost := BlackboxHost{Labels: entryLabels}
+	blackboxHost.Labels.Extra = make(map[string]string)
+	for name, value := range entryLabels.Extra {
+		blackboxHost.Labels.Extra[name] = value
+	}
+	targetURL, ok := target["url"]
+	if !ok {
+		recordValidationError(config.blackbox.configName, "the target objects should have an url")
+		return blackboxHost, false
+	}
+	renderedTarget, err := renderTarget(fmt.Sprintf("%v", targetURL), hostVariables)
+	if err != nil {
+		recordValidationError(config.blackbox.configName, err.Error())
+		return blackboxHost, false
+	}
+	if module, ok := target["module"]; ok {
+		blackboxHost.Labels.Module = fmt.Sprintf("%v", module)
+	}
+	err = validateTarget(config, blackboxHost.Labels.Module, renderedTarget)
+	if err != nil {
+		recordValidationError(config.blackbox.configName, err.Error())
+		return blackboxHost, false
+	}
+	blackboxHost.Targets = []string{renderedTarget}
+	if interval, ok := target["interval"]; ok {
+		blackboxHost.Labels.Extra["__scrape_interval__"] = fmt.Sprintf("%v", interval)
+	}
+	if labelsConfig, ok := target["labels"]; ok {
+		labels, ok := labelsConfig.(map[string]interface{})
+		if !ok {
+			recordValidationError(config.blackbox.configName, fmt.Sprintf("the labels of the target %s should be a map", renderedTarget))
+			return blackboxHost, false
+		}
+		for name, value := range labels {
+			if labelNameRegexp.MatchString(name) == false || inSlice(name, reservedBlackboxLabels) || name[0] == '_' {
+				recordValidationError(config.blackbox.configName, fmt.Sprintf("the label %s of the target %s can not be set", name, renderedTarget))
+				continue
+			}
+			renderedValue, err := renderTarget(fmt.Sprintf("%v", value), hostVariables)
+			if err != nil {
+				recordValidationError(config.blackbox.configName, err.Error())
+				continue
+			}
+			blackboxHost.Labels.Extra[name] = renderedValue
+		}
+	}
+	return blackboxHost, true
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+/// extractVariableValues Returns the values of the given variable path, the keys are separated by dots and
+/// a key ending with [] takes the values of all the items of the list, e.g. nginx_vhosts[].server_name
+func extractVariableValues(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if list, ok := value.([]interface{}); ok {
+			return list
+		}
+		return []interface{}{value}
+	}
+	mapValue, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	key := path[0]
+	isList := strings.HasSuffix(key, "[]")
+	value, ok = mapValue[strings.TrimSuffix(key, "[]")]
+	if !ok {
+		return nil
+	}
+	if !isList {
+		return extractVariableValues(value, path[1:])
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	var values []interface{}
+	for _, item := range list {
+		values = append(values, extractVariableValues(item, path[1:])...)
+	}
+	return values
+}
+
+/// getTLSTargets Returns the tls targets of the host from the TLSVariables. A value can contain several
+/// names separated by spaces, the TLSPort is added to the names without port.
+func getTLSTargets(config Config, hostVariables map[string]interface{}) []interface{} {
+	var targets []interface{}
+	seen := make(map[string]bool)
+	for _, variablePath := range config.blackbox.tlsVariables {
+		variablePath = strings.TrimSpace(variablePath)
+		if variablePath == "" {
+			continue
+		}
+		for _, value := range extractVariableValues(hostVariables, strings.Split(variablePath, ".")) {
+			if value == nil {
+				continue
+			}
+			for _, name := range strings.Fields(fmt.Sprintf("%v", value)) {
+				// Wildcard and catch all names like _ of nginx can not be probed
+				if strings.HasPrefix(name, "*") || name == "_" {
+					continue
+				}
+				target := name
+				if strings.Contains(name, ":") == false {
+					target = fmt.Sprintf("%s:%d", name, config.blackbox.tlsPort)
+				}
+				if seen[target] == false {
+					seen[target] = true
+					targets = append(targets, target)
+				}
+			}
+		}
+	}
+	return targets
+}
+
+/// getTLSEntry Returns the blackbox entry of the tls targets of the host, false when there are none
+func getTLSEntry(config Config, hostVariables map[string]interface{}) (map[string]interface{}, bool) {
+	targets := getTLSTargets(config, hostVariables)
+	if len(targets) == 0 {
+		return nil, false
+	}
+	return map[string]interface{}{"module": config.blackbox.tlsModule, "targets": targets}, true
+}
+
+/// getHostsWithTLSVariables Returns the hosts that have one of the TLSVariables
+func getHostsWithTLSVariables(config Config) ([]Host, error) {
+	var hosts []Host
+	for _, variablePath := range config.blackbox.tlsVariables {
+		variableName := strings.TrimSuffix(strings.Split(strings.TrimSpace(variablePath), ".")[0], "[]")
+		if variableName == "" {
+			continue
+		}
+		var err error
+		hosts, err = getAllHosts(config, "host_filter=variables__icontains="+url.QueryEscape(variableName), hosts)
+		if err != nil {
+			return hosts, err
+		}
+	}
+	return hosts, nil
+}
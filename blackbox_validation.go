@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+/// Resolver resolves the hostnames of the blackbox targets, the net.Resolver implements it
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+/// targetResolver is the resolver of the target hostnames, it can be replaced e.g. in the tests
+var targetResolver Resolver = net.DefaultResolver
+
+/// resolveTimeout is the timeout of a single hostname lookup
+var resolveTimeout = 2 * time.Second
+
+/// hostnameRegexp matches the valid hostnames
+var hostnameRegexp = regexp.MustCompile(`^([a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9])?\.)*[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9])?\.?$`)
+
+/// moduleNamePrefixes are the prober types of the modules that are not defined, derived from the module name
+var moduleNamePrefixes = []struct {
+	prefix string
+	prober string
+}{
+	{"http", "http"},
+	{"tcp", "tcp"},
+	{"tls", "tcp"},
+	{"ssh", "tcp"},
+	{"icmp", "icmp"},
+	{"ping", "icmp"},
+	{"dns", "dns"},
+}
+
+/// loadModuleProbers Returns the prober types of the modules from the ModuleProbers setting and the modules of
+/// the ModulesSourceFile. The source file is optional here, lint reports when it can not be read.
+func loadModuleProbers(blackbox BlackboxConfig, moduleProbers []string) (map[string]string, error) {
+	probers := make(map[string]string)
+	if blackbox.modulesSourceFile != "" {
+		var blackboxFileConfig struct {
+			Modules map[string]struct {
+				Prober string `yaml:"prober"`
+			} `yaml:"modules"`
+		}
+		content, err := ioutil.ReadFile(blackbox.modulesSourceFile)
+		if err == nil && yaml.Unmarshal(content, &blackboxFileConfig) == nil {
+			for name, module := range blackboxFileConfig.Modules {
+				probers[name] = module.Prober
+			}
+		}
+	}
+	for _, moduleProber := range moduleProbers {
+		parts := strings.SplitN(moduleProber, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("The module prober %s of BLACKBOX.ModuleProbers should be in the module=prober format", moduleProber)
+		}
+		probers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return probers, nil
+}
+
+/// getModuleProber Returns the prober type of the module or an empty string when it is unknown
+func getModuleProber(config Config, module string) string {
+	if prober, ok := config.blackbox.moduleProbers[module]; ok {
+		return prober
+	}
+	for _, namePrefix := range moduleNamePrefixes {
+		if strings.HasPrefix(module, namePrefix.prefix) {
+			return namePrefix.prober
+		}
+	}
+	return ""
+}
+
+/// validateHost Returns an error when the host is neither an IP address nor a valid hostname
+func validateHost(host string) error {
+	if host == "" {
+		return fmt.Errorf("the host is empty")
+	}
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return nil
+	}
+	if hostnameRegexp.MatchString(host) == false {
+		return fmt.Errorf("%s is not a valid hostname or IP address", host)
+	}
+	return nil
+}
+
+/// validateHostPort Returns the host of the target when it is host:port with a valid port, the host itself
+/// is only checked to be set like the tcp prober of the blackbox exporter does
+func validateHostPort(target string) (string, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || host == "" {
+		return "", fmt.Errorf("%s should be host:port", target)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil || portNumber < 1 || portNumber > 65535 {
+		return "", fmt.Errorf("%s has the invalid port %s", target, port)
+	}
+	return host, nil
+}
+
+/// resolveHost Returns an error when the hostname does not resolve, IP addresses are not resolved
+func resolveHost(host string) error {
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	_, err := targetResolver.LookupHost(ctx, host)
+	if err != nil {
+		return fmt.Errorf("%s does not resolve: %v", host, err)
+	}
+	return nil
+}
+
+/// validateTarget Returns an error when the target is not valid for the prober of the module: http needs an
+/// url, tcp host:port and icmp and dns a hostname or IP address. The http targets without scheme are valid,
+/// the blackbox exporter adds http:// to them. With ResolveTargets the hostname should resolve. The targets
+/// of the unknown probers are not validated.
+func validateTarget(config Config, module string, target string) error {
+	var host string
+	var err error
+	switch getModuleProber(config, module) {
+	case "http":
+		targetURL := target
+		if strings.Contains(targetURL, "://") == false {
+			targetURL = "http://" + targetURL
+		}
+		parsedURL, parseErr := url.Parse(targetURL)
+		if parseErr != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			return fmt.Errorf("the target %s of the module %s is not a valid http url", target, module)
+		}
+		host = parsedURL.Hostname()
+		err = validateHost(host)
+	case "tcp":
+		host, err = validateHostPort(target)
+	case "icmp":
+		host = target
+		err = validateHost(host)
+	case "dns":
+		host = target
+		if strings.Contains(target, ":") && net.ParseIP(strings.Trim(target, "[]")) == nil {
+			host, err = validateHostPort(target)
+		} else {
+			err = validateHost(host)
+		}
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("the target %s of the module %s is invalid: %v", target, module, err)
+	}
+	if config.blackbox.resolveTargets {
+		if err := resolveHost(host); err != nil {
+			return fmt.Errorf("the target %s of the module %s is invalid: %v", target, module, err)
+		}
+	}
+	return nil
+}
+
+/// validationErrorListener receives the validation errors while collectValidationErrors runs
+var validationErrorListener = struct {
+	sync.Mutex
+	listener func(variableName string, reason string)
+}{}
+
+/// collectValidationErrors Runs the given function and returns the validation errors it recorded, which are
+/// not logged, so the caller reports each of them once
+func collectValidationErrors(run func()) []string {
+	var reasons []string
+	validationErrorListener.Lock()
+	validationErrorListener.listener = func(variableName string, reason string) {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", variableName, reason))
+	}
+	validationErrorListener.Unlock()
+	defer func() {
+		validationErrorListener.Lock()
+		validationErrorListener.listener = nil
+		validationErrorListener.Unlock()
+	}()
+	run()
+	return reasons
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/// version is the version of the exporter, it is set on build with -ldflags "-X main.version=<version>"
+var version = "dev"
+
+/// Command is a subcommand of the exporter
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string) int
+}
+
+/// RunOptions contains the flags of the commands that create the outputs
+type RunOptions struct {
+	configPath      string
+	overrides       ConfigOverrides
+	watch           bool
+	interval        time.Duration
+	force           bool
+	metricsTextfile string
+	metricsAddress  string
+	outputDir       string
+	outputs         map[string]string
+}
+
+/// getCommands Returns all the subcommands of the exporter
+func getCommands() []Command {
+	var commands []Command
+	for _, mode := range getModes() {
+		commands = append(commands, modeCommand(mode))
+	}
+	return append(commands,
+		Command{
+			Name:  "all",
+			Usage: "Creates the prometheus, blackbox and alertmanager modes and the modes with an output flag or, with -watch, an output file",
+			Run:   runAll,
+		},
+		Command{
+			Name:  "serve",
+			Usage: "Runs the http service discovery server",
+			Run:   serve,
+		},
+		Command{
+			Name:  "lint",
+			Usage: "Checks the configuration, the source files and the AWX variables",
+			Run:   lint,
+		},
+		Command{
+			Name:  "explain",
+			Usage: "Shows where the targets and receivers of the given host come from",
+			Run:   explain,
+		},
+		Command{
+			Name:  "coverage",
+			Usage: "Lists the hosts and groups without monitoring per organization and inventory",
+			Run:   coverage,
+		},
+		Command{
+			Name:  "version",
+			Usage: "Prints the version of the exporter",
+			Run: func(args []string) int {
+				fmt.Printf("awx-exporter %s\n", version)
+				return 0
+			},
+		},
+	)
+}
+
+/// printUsage Prints the subcommands of the exporter
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: awx-exporter <command> [flags]\n\nCommands:\n")
+	for _, command := range getCommands() {
+		fmt.Fprintf(w, "  %-18s %s\n", command.Name, command.Usage)
+	}
+	fmt.Fprintf(w, "\nRun awx-exporter <command> -h for the flags of the command.\n")
+}
+
+/// runCommand Runs the subcommand of the given arguments and returns the exit code
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return 2
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return 0
+	}
+	if strings.HasPrefix(args[0], "-") {
+		return runLegacy(args)
+	}
+	for _, command := range getCommands() {
+		if command.Name == args[0] {
+			return command.Run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+	printUsage(os.Stderr)
+	return 2
+}
+
+/// newFlagSet Creates the flag set of the given command with its help text
+func newFlagSet(name string, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: awx-exporter %s [flags]\n\n%s\n\nFlags:\n", name, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+/// parseFlags Parses the arguments of the command, returns false with the exit code when the command
+/// should not run
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0, false
+	}
+	if err != nil {
+		return 2, false
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "Unexpected arguments: %s\n", strings.Join(flags.Args(), " "))
+		flags.Usage()
+		return 2, false
+	}
+	return 0, true
+}
+
+/// addConfigFlags Adds the flags of the configuration path and the overrides
+func addConfigFlags(flags *flag.FlagSet, configPath *string, overrides *ConfigOverrides) {
+	defaultPath := os.Getenv(envPrefix + "CONFIG_PATH")
+	if defaultPath == "" {
+		defaultPath = "config.ini"
+	}
+	flags.StringVar(configPath, "config-path", defaultPath, "The path to the configuration, also set with "+envPrefix+"CONFIG_PATH")
+	flags.Var(overrides, "set", "Overrides a configuration key in the SECTION.Key=value format, can be repeated")
+}
+
+/// addRunFlags Adds the flags of the commands that create the outputs
+func addRunFlags(flags *flag.FlagSet, options *RunOptions) {
+	addConfigFlags(flags, &options.configPath, &options.overrides)
+	flags.BoolVar(&options.watch, "watch", false, "Keeps running and writes the modes to their output files")
+	flags.DurationVar(&options.interval, "interval", 5*time.Minute, "The interval of the watch mode")
+	flags.BoolVar(&options.force, "force", false, "Writes the output files even when the safety guard blocks them")
+	flags.StringVar(&options.metricsTextfile, "metrics-textfile", "", "Writes the exporter metrics to the given node_exporter textfile")
+	flags.StringVar(&options.metricsAddress, "metrics-address", "", "The address of the metrics endpoint in the watch mode")
+	flags.StringVar(&options.outputDir, "output-dir", "", "Writes the modes without output file to <mode>.<extension> in the given directory")
+}
+
+/// validate Returns an error for the invalid combinations of the flags
+func (options RunOptions) validate(flags *flag.FlagSet) error {
+	visited := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { visited[f.Name] = true })
+	if options.watch {
+		if options.force {
+			return errors.New("-force can not be used with -watch, the safety guard is always active in the watch mode")
+		}
+		if options.metricsTextfile != "" {
+			return errors.New("-metrics-textfile can not be used with -watch, use -metrics-address instead")
+		}
+		return nil
+	}
+	if visited["interval"] {
+		return errors.New("-interval can only be used with -watch")
+	}
+	if options.metricsAddress != "" {
+		return errors.New("-metrics-address can only be used with -watch")
+	}
+	return nil
+}
+
+/// modeCommand Returns the command that creates the output of the given mode
+func modeCommand(mode Mode) Command {
+	return Command{
+		Name:  mode.Name,
+		Usage: mode.Usage,
+		Run: func(args []string) int {
+			options := RunOptions{outputs: make(map[string]string)}
+			flags := newFlagSet(mode.Name, mode.Usage)
+			addRunFlags(flags, &options)
+			output := flags.String("output", "", "The output file, overrides the config")
+			if exitCode, ok := parseFlags(flags, args); !ok {
+				return exitCode
+			}
+			if *output != "" {
+				options.outputs[mode.Name] = *output
+			}
+			return runModes(flags, options, func(config Config) []Mode { return []Mode{mode} })
+		},
+	}
+}
+
+/// runAll Runs the prometheus, blackbox and alertmanager modes and the other modes that have an output flag,
+/// with -watch also the modes that have an output file in the configuration
+func runAll(args []string) int {
+	options := RunOptions{outputs: make(map[string]string)}
+	flags := newFlagSet("all", "Creates the prometheus, blackbox and alertmanager modes and the modes with an output flag or, with -watch, an output file")
+	addRunFlags(flags, &options)
+	outputs := make(map[string]*string)
+	for _, mode := range getModes() {
+		outputs[mode.Name] = flags.String(mode.Name+"-output", "", "The output file of the "+mode.Name+" mode, overrides the config")
+	}
+	if exitCode, ok := parseFlags(flags, args); !ok {
+		return exitCode
+	}
+	for name, output := range outputs {
+		if *output != "" {
+			options.outputs[name] = *output
+		}
+	}
+	return runModes(flags, options, func(config Config) []Mode {
+		var modes []Mode
+		for _, mode := range getModes() {
+			switch {
+			case mode.Name == "prometheus" || mode.Name == "blackbox" || mode.Name == "alertmanager":
+			case options.outputs[mode.Name] != "":
+			case options.watch && mode.OutputFile(config) != "":
+			default:
+				continue
+			}
+			modes = append(modes, mode)
+		}
+		return modes
+	})
+}
+
+/// runLegacy Runs the mode flags of the previous versions, e.g. -prometheus -blackbox
+func runLegacy(args []string) int {
+	options := RunOptions{outputs: make(map[string]string)}
+	flags := newFlagSet("[-<mode>...]", "The mode flags are deprecated, use the subcommands instead")
+	addRunFlags(flags, &options)
+	enabled := make(map[string]*bool)
+	outputs := make(map[string]*string)
+	for _, mode := range getModes() {
+		enabled[mode.Name] = flags.Bool(mode.Name, false, mode.Usage)
+		outputs[mode.Name] = flags.String(mode.Name+"-output", "", "The output file of the "+mode.Name+" mode, overrides the config")
+	}
+	if exitCode, ok := parseFlags(flags, args); !ok {
+		return exitCode
+	}
+	var modes []Mode
+	for _, mode := range getModes() {
+		if *enabled[mode.Name] {
+			modes = append(modes, mode)
+		}
+		if *outputs[mode.Name] != "" {
+			options.outputs[mode.Name] = *outputs[mode.Name]
+		}
+	}
+	if len(modes) == 0 {
+		fmt.Fprintf(os.Stderr, "No mode is enabled\n\n")
+		printUsage(os.Stderr)
+		return 2
+	}
+	log.Printf("The mode flags are deprecated, use the subcommands instead, e.g. awx-exporter %s", modes[0].Name)
+	return runModes(flags, options, func(config Config) []Mode { return modes })
+}
+
+/// runModes Loads the configuration and creates the selected modes once or in the watch mode
+func runModes(flags *flag.FlagSet, options RunOptions, selectModes func(Config) []Mode) int {
+	err := options.validate(flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	config, err := loadConfiguration(options.configPath, options.overrides)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	selectRunModes := func(config Config) []Mode {
+		return getRunModes(config, options, selectModes)
+	}
+	modes := selectRunModes(config)
+	if options.watch {
+		err = checkOutputFiles(config, modes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can not run the watch mode: %v\n", err)
+			return 2
+		}
+		watch(options.configPath, options.overrides, config, modes, selectRunModes, options.interval, options.metricsAddress)
+		return 0
+	}
+	exitCode := runOnce(config, modes, options)
+	if options.metricsTextfile != "" {
+		if err := writeMetricsTextfile(options.metricsTextfile); err != nil {
+			log.Printf("Error writing the metrics textfile %v", err)
+		}
+	}
+	return exitCode
+}
+
+/// getRunModes Returns the selected modes of the configuration with the output files of the output flags and
+/// the output directory
+func getRunModes(config Config, options RunOptions, selectModes func(Config) []Mode) []Mode {
+	var modes []Mode
+	for _, mode := range selectModes(config) {
+		output := options.outputs[mode.Name]
+		if output == "" && options.outputDir != "" {
+			output = filepath.Join(options.outputDir, mode.Name+"."+mode.Extension)
+			options.outputs[mode.Name] = output
+		}
+		if output != "" {
+			mode.OutputFile = func(config Config) string { return output }
+		}
+		modes = append(modes, mode)
+	}
+	return modes
+}
+
+/// runOnce Creates the given modes and prints them or writes them to their output files
+func runOnce(config Config, modes []Mode, options RunOptions) int {
+	source, err := loadSource(config, modes)
+	if err != nil {
+		log.Printf("Error loading the AWX inventory %v", err)
+		return 1
+	}
+	// The inventory metrics are only read from the textfile in the one shot mode
+	if options.metricsTextfile != "" {
+		refreshInventoryMetrics(config, source)
+	}
+	stdoutModes := 0
+	for _, mode := range modes {
+		if options.outputs[mode.Name] == "" {
+			stdoutModes++
+		}
+	}
+	exitCode := exitUnchanged
+	for _, mode := range modes {
+		content, err := runMode(config, mode, source)
+		if err != nil {
+			log.Printf("Error creating the %s mode %v", mode.Name, err)
+			return 1
+		}
+		if options.outputs[mode.Name] == "" {
+			// Several outputs on stdout are separated by a header with the name of the mode
+			if stdoutModes > 1 {
+				fmt.Printf("# %s\n", mode.Name)
+			}
+			fmt.Println(string(content))
+			continue
+		}
+		changed, err := writeModeOutput(config, mode, content, options.force)
+		if errors.Is(err, errSafetyGuard) {
+			log.Printf("Not writing the %s mode, use -force to write it anyway: %v", mode.Name, err)
+			exitCode = exitBlocked
+			continue
+		}
+		if err != nil {
+			log.Printf("Error writing the %s mode %v", mode.Name, err)
+			return 1
+		}
+		if changed && exitCode != exitBlocked {
+			exitCode = exitChanged
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/// CoverageEntry is a host or group that is not monitored completely
+type CoverageEntry struct {
+	OrganizationId int      `json:"organization_id"`
+	Inventory      string   `json:"inventory"`
+	Kind           string   `json:"kind"`
+	Name           string   `json:"name"`
+	Missing        []string `json:"missing"`
+}
+
+/// CoverageSummary contains the number of the uncovered hosts and groups of an inventory
+type CoverageSummary struct {
+	OrganizationId            int    `json:"organization_id"`
+	Inventory                 string `json:"inventory"`
+	Hosts                     int    `json:"hosts"`
+	HostsWithoutPrometheus    int    `json:"hosts_without_prometheus"`
+	HostsWithoutBlackbox      int    `json:"hosts_without_blackbox"`
+	Groups                    int    `json:"groups"`
+	GroupsWithoutAlertmanager int    `json:"groups_without_alertmanager"`
+}
+
+/// CoverageReport lists the hosts and groups without monitoring
+type CoverageReport struct {
+	Summary   []CoverageSummary `json:"summary"`
+	Uncovered []CoverageEntry   `json:"uncovered"`
+}
+
+/// hasPrometheusTargets Checks if the prometheus generator creates a target of the host in one of its groups
+func hasPrometheusTargets(config Config, host *ModelHost, groups []*ModelGroup) bool {
+	for _, group := range groups {
+		prometheusConfig, ok := group.Variables[config.prometheus.configName]
+		if !ok {
+			continue
+		}
+		for _, prometheusHost := range createPrometheusHosts(config, group.Group.Name, host.Variables, prometheusConfig, nil) {
+			if len(prometheusHost.Targets) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/// hasBlackboxProbes Checks if the blackbox generator creates a target of the host from its groups or its own
+/// config, so the targets that are dropped by the validation are not counted
+func hasBlackboxProbes(config Config, host *ModelHost, groups []*ModelGroup) bool {
+	groupConfigs := getBlackboxGroupConfigs(config, groups)
+	for _, blackboxHost := range createHostBlackboxHosts(config, host.Host, getModelGroupNames(groups), host.getTemplateVariables(), groupConfigs, nil) {
+		if len(blackboxHost.Targets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/// hasAlertManagerReceiver Checks if the alertmanager generator creates a receiver of the group
+func hasAlertManagerReceiver(config Config, group *ModelGroup) bool {
+	alertManagerConfig, ok := group.Variables[config.alertmanager.configName]
+	return ok && len(createAlertManagerNotifiers(config, group.Group.Name, alertManagerConfig, nil)) > 0
+}
+
+/// createCoverageReport Returns the hosts without prometheus targets or blackbox probes and the groups
+/// without alertmanager receiver per organization and inventory. The invalid entries are skipped silently,
+/// they are reported by lint.
+func createCoverageReport(config Config, model *InventoryModel) CoverageReport {
+	var report CoverageReport
+	collectValidationErrors(func() {
+		report = createModelCoverageReport(config, model)
+	})
+	return report
+}
+
+/// createModelCoverageReport Returns the coverage report of the hosts and groups of the model
+func createModelCoverageReport(config Config, model *InventoryModel) CoverageReport {
+	report := CoverageReport{Summary: []CoverageSummary{}, Uncovered: []CoverageEntry{}}
+	summaries := make(map[[2]string]*CoverageSummary)
+	getSummary := func(inventory InventorySummary) *CoverageSummary {
+		key := [2]string{strconv.Itoa(inventory.OrganizationId), inventory.Name}
+		if _, ok := summaries[key]; !ok {
+			summaries[key] = &CoverageSummary{OrganizationId: inventory.OrganizationId, Inventory: inventory.Name}
+		}
+		return summaries[key]
+	}
+	hostGroups := model.getHostGroupIndex()
+	for _, host := range model.sortedHosts() {
+		inventory := host.Host.SummaryFields.Inventory
+		summary := getSummary(inventory)
+		summary.Hosts++
+		var missing []string
+		if hasPrometheusTargets(config, host, hostGroups[host.Host.ID]) == false {
+			summary.HostsWithoutPrometheus++
+			missing = append(missing, "prometheus")
+		}
+		if hasBlackboxProbes(config, host, hostGroups[host.Host.ID]) == false {
+			summary.HostsWithoutBlackbox++
+			missing = append(missing, "blackbox")
+		}
+		if len(missing) > 0 {
+			report.Uncovered = append(report.Uncovered, CoverageEntry{inventory.OrganizationId, inventory.Name, "host", host.Host.Name, missing})
+		}
+	}
+	for _, group := range model.sortedGroups() {
+		inventory := group.Group.SummaryFields.Inventory
+		summary := getSummary(inventory)
+		summary.Groups++
+		if hasAlertManagerReceiver(config, group) == false {
+			summary.GroupsWithoutAlertmanager++
+			report.Uncovered = append(report.Uncovered, CoverageEntry{inventory.OrganizationId, inventory.Name, "group", group.Group.Name, []string{"alertmanager"}})
+		}
+	}
+	for _, summary := range summaries {
+		report.Summary = append(report.Summary, *summary)
+	}
+	sort.Slice(report.Summary, func(i, j int) bool {
+		if report.Summary[i].OrganizationId != report.Summary[j].OrganizationId {
+			return report.Summary[i].OrganizationId < report.Summary[j].OrganizationId
+		}
+		return report.Summary[i].Inventory < report.Summary[j].Inventory
+	})
+	sort.SliceStable(report.Uncovered, func(i, j int) bool {
+		left, right := report.Uncovered[i], report.Uncovered[j]
+		if left.OrganizationId != right.OrganizationId {
+			return left.OrganizationId < right.OrganizationId
+		}
+		return left.Inventory < right.Inventory
+	})
+	return report
+}
+
+var (
+	coverageHostsDesc = prometheus.NewDesc(
+		"awx_exporter_coverage_hosts",
+		"The number of AWX hosts by organization and inventory.",
+		[]string{"organization", "inventory"}, nil)
+	coverageUncoveredHostsDesc = prometheus.NewDesc(
+		"awx_exporter_coverage_uncovered_hosts",
+		"The number of AWX hosts without prometheus targets or blackbox probes by organization and inventory.",
+		[]string{"organization", "inventory", "check"}, nil)
+	coverageGroupsDesc = prometheus.NewDesc(
+		"awx_exporter_coverage_groups",
+		"The number of AWX groups by organization and inventory.",
+		[]string{"organization", "inventory"}, nil)
+	coverageUncoveredGroupsDesc = prometheus.NewDesc(
+		"awx_exporter_coverage_uncovered_groups",
+		"The number of AWX groups without alertmanager receiver by organization and inventory.",
+		[]string{"organization", "inventory"}, nil)
+)
+
+/// CoverageCollector exposes the coverage of the last refresh, the metrics are replaced at once so a scrape
+/// never sees a partial report
+type CoverageCollector struct {
+	mutex   sync.RWMutex
+	metrics []prometheus.Metric
+}
+
+/// coverageCollector is registered in the metrics registry
+var coverageCollector = &CoverageCollector{}
+
+/// Describe implements the prometheus.Collector interface
+func (collector *CoverageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- coverageHostsDesc
+	ch <- coverageUncoveredHostsDesc
+	ch <- coverageGroupsDesc
+	ch <- coverageUncoveredGroupsDesc
+}
+
+/// Collect implements the prometheus.Collector interface
+func (collector *CoverageCollector) Collect(ch chan<- prometheus.Metric) {
+	collector.mutex.RLock()
+	defer collector.mutex.RUnlock()
+	for _, metric := range collector.metrics {
+		ch <- metric
+	}
+}
+
+/// recordCoverage Replaces the coverage metrics with the ones of the report
+func recordCoverage(report CoverageReport) {
+	var metrics []prometheus.Metric
+	for _, summary := range report.Summary {
+		organization := strconv.Itoa(summary.OrganizationId)
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(coverageHostsDesc, prometheus.GaugeValue, float64(summary.Hosts), organization, summary.Inventory),
+			prometheus.MustNewConstMetric(coverageUncoveredHostsDesc, prometheus.GaugeValue, float64(summary.HostsWithoutPrometheus), organization, summary.Inventory, "prometheus"),
+			prometheus.MustNewConstMetric(coverageUncoveredHostsDesc, prometheus.GaugeValue, float64(summary.HostsWithoutBlackbox), organization, summary.Inventory, "blackbox"),
+			prometheus.MustNewConstMetric(coverageGroupsDesc, prometheus.GaugeValue, float64(summary.Groups), organization, summary.Inventory),
+			prometheus.MustNewConstMetric(coverageUncoveredGroupsDesc, prometheus.GaugeValue, float64(summary.GroupsWithoutAlertmanager), organization, summary.Inventory),
+		)
+	}
+	coverageCollector.mutex.Lock()
+	coverageCollector.metrics = metrics
+	coverageCollector.mutex.Unlock()
+}
+
+/// refreshCoverage Sets the coverage metrics from the inventory model. Without incremental sync the model is
+/// loaded for the report, when the source failed the metrics of the last refresh are kept.
+func refreshCoverage(config Config, source InventorySource) {
+	switch source := source.(type) {
+	case *InventoryModel:
+		recordCoverage(createCoverageReport(config, source))
+	case failedSource:
+	default:
+		model, err := loadInventoryModel(config)
+		if err != nil {
+			log.Printf("Error loading the AWX inventory for the coverage metrics, keeping the last ones: %v", err)
+			return
+		}
+		recordCoverage(createCoverageReport(config, model))
+	}
+}
+
+/// writeCoverageReport Writes the report in the given format, which is table, csv or json
+func writeCoverageReport(w io.Writer, report CoverageReport, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "csv":
+		writer := csv.NewWriter(w)
+		_ = writer.Write([]string{"organization_id", "inventory", "kind", "name", "missing"})
+		for _, entry := range report.Uncovered {
+			_ = writer.Write([]string{strconv.Itoa(entry.OrganizationId), entry.Inventory, entry.Kind, entry.Name, strings.Join(entry.Missing, ",")})
+		}
+		writer.Flush()
+		return writer.Error()
+	case "table":
+		writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "ORGANIZATION\tINVENTORY\tHOSTS\tWITHOUT PROMETHEUS\tWITHOUT BLACKBOX\tGROUPS\tWITHOUT ALERTMANAGER")
+		for _, summary := range report.Summary {
+			fmt.Fprintf(writer, "%d\t%s\t%d\t%d\t%d\t%d\t%d\n",
+				summary.OrganizationId,
+				summary.Inventory,
+				summary.Hosts,
+				summary.HostsWithoutPrometheus,
+				summary.HostsWithoutBlackbox,
+				summary.Groups,
+				summary.GroupsWithoutAlertmanager)
+		}
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "ORGANIZATION\tINVENTORY\tKIND\tNAME\tMISSING")
+		for _, entry := range report.Uncovered {
+			fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s\n", entry.OrganizationId, entry.Inventory, entry.Kind, entry.Name, strings.Join(entry.Missing, ","))
+		}
+		return writer.Flush()
+	}
+	return fmt.Errorf("the format %q is not supported, use table, csv or json", format)
+}
+
+/// coverage Prints the hosts and groups without monitoring
+func coverage(args []string) int {
+	flags := newFlagSet("coverage", "Lists the hosts and groups without monitoring per organization and inventory")
+	var configPath string
+	var overrides ConfigOverrides
+	addConfigFlags(flags, &configPath, &overrides)
+	format := flags.String("format", "table", "The format of the report: table, csv or json")
+	if exitCode, ok := parseFlags(flags, args); !ok {
+		return exitCode
+	}
+	if *format != "table" && *format != "csv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "The format %q is not supported, use table, csv or json\n", *format)
+		return 2
+	}
+	config, err := loadConfiguration(configPath, overrides)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	model, err := loadInventoryModel(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "The AWX inventory can not be loaded: %v\n", err)
+		return 1
+	}
+	err = writeCoverageReport(os.Stdout, createCoverageReport(config, model), *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/// formatVariable Returns the json representation of the given variable value
+func formatVariable(value interface{}) string {
+	out, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(out)
+}
+
+/// explainPrometheus Writes the groups and variables the prometheus targets of the host come from
+func explainPrometheus(w io.Writer, config Config, host *ModelHost, groups []*ModelGroup) {
+	fmt.Fprintf(w, "Prometheus (%s):\n", config.prometheus.configName)
+	hostConfig, hostHasConfig := host.Variables[config.prometheus.configName]
+	found := false
+	for _, group := range groups {
+		groupConfig, ok := group.Variables[config.prometheus.configName]
+		if !ok {
+			continue
+		}
+		found = true
+		fmt.Fprintf(w, "  group %s provides %s\n", group.Group.Name, formatVariable(groupConfig))
+		if hostHasConfig && config.prometheus.configHostOverride {
+			fmt.Fprintf(w, "  host variable overrides it (ConfigHostOverride=true): %s\n", formatVariable(hostConfig))
+		} else if hostHasConfig {
+			fmt.Fprintf(w, "  host variable is ignored (ConfigHostOverride=false)\n")
+		}
+		for _, prometheusHost := range createPrometheusHosts(config, group.Group.Name, host.Variables, groupConfig, nil) {
+			fmt.Fprintf(w, "  => labels %s targets %s\n", formatVariable(prometheusHost.Labels), strings.Join(prometheusHost.Targets, ","))
+		}
+	}
+	if !found {
+		if hostHasConfig {
+			fmt.Fprintf(w, "  host variable is ignored, no group of the host has %s\n", config.prometheus.configName)
+		} else {
+			fmt.Fprintf(w, "  no group of the host has %s\n", config.prometheus.configName)
+		}
+	}
+}
+
+/// explainBlackbox Writes the group selection and the variables the blackbox targets of the host come from
+func explainBlackbox(w io.Writer, config Config, host *ModelHost, groups []*ModelGroup) {
+	fmt.Fprintf(w, "Blackbox (%s):\n", config.blackbox.configName)
+	groupConfigs := getBlackboxGroupConfigs(config, groups)
+	groupHasConfig := false
+	for _, groupConfig := range groupConfigs {
+		if groupConfig.Config != nil {
+			groupHasConfig = true
+			fmt.Fprintf(w, "  group %s provides %s\n", groupConfig.Group, formatVariable(groupConfig.Config))
+		}
+		if groupConfig.Probers != nil {
+			fmt.Fprintf(w, "  group %s sets the default probers %s (%s)\n", groupConfig.Group, formatVariable(groupConfig.Probers), config.blackbox.probersConfigName)
+		}
+	}
+	blackboxConfig, hostHasConfig := host.Variables[config.blackbox.configName]
+	tlsTargets := getTLSTargets(config, host.Variables)
+	if len(tlsTargets) > 0 {
+		fmt.Fprintf(w, "  TLSVariables provide the %s targets %s\n", config.blackbox.tlsModule, formatVariable(tlsTargets))
+	}
+	if hostHasConfig {
+		fmt.Fprintf(w, "  host provides %s\n", formatVariable(blackboxConfig))
+		if groupHasConfig {
+			fmt.Fprintf(w, "  host entries are merged on top of the group entries with the same module\n")
+		}
+	}
+	if hostHasConfig || len(tlsTargets) > 0 {
+		groupNames := getModelGroupNames(groups)
+		for _, group := range groupNames {
+			if matchAnyGroupPattern(config.blackbox.IgnoredGroups, group) {
+				fmt.Fprintf(w, "  group %s is ignored (IgnoredGroups)\n", group)
+			}
+		}
+		selected := selectBlackboxGroups(config, groupNames)
+		if len(selected) == 0 {
+			fmt.Fprintf(w, "  the other host entries are skipped, all the groups of the host are ignored and no FallbackGroup is set\n")
+		} else if len(selected) == 1 && inSlice(selected[0], groupNames) == false {
+			fmt.Fprintf(w, "  the other host entries use the FallbackGroup %s, all the groups of the host are ignored\n", selected[0])
+		}
+		for _, group := range selected {
+			if inSlice(group, groupNames) {
+				fmt.Fprintf(w, "  group %s is used for the other host entries (GroupStrategy)\n", group)
+			}
+		}
+	}
+	if !hostHasConfig && !groupHasConfig && len(tlsTargets) == 0 {
+		fmt.Fprintf(w, "  neither the host nor its groups have %s\n", config.blackbox.configName)
+		return
+	}
+	var blackboxHosts []BlackboxHost
+	skipped := collectValidationErrors(func() {
+		blackboxHosts = createHostBlackboxHosts(config, host.Host, getModelGroupNames(groups), host.getTemplateVariables(), groupConfigs, nil)
+	})
+	for _, reason := range skipped {
+		fmt.Fprintf(w, "  skipped %s\n", reason)
+	}
+	for _, blackboxHost := range blackboxHosts {
+		fmt.Fprintf(w, "  => labels %s targets %s\n", formatVariable(blackboxHost.Labels), strings.Join(blackboxHost.Targets, ","))
+	}
+}
+
+/// explainAlertManager Writes the groups the alertmanager receivers of the host come from
+func explainAlertManager(w io.Writer, config Config, host *ModelHost, groups []*ModelGroup) {
+	fmt.Fprintf(w, "Alertmanager (%s):\n", config.alertmanager.configName)
+	found := false
+	for _, group := range groups {
+		alertManagerConfig, ok := group.Variables[config.alertmanager.configName]
+		if !ok {
+			continue
+		}
+		found = true
+		fmt.Fprintf(w, "  group %s provides %s\n", group.Group.Name, formatVariable(alertManagerConfig))
+		for _, notifier := range createAlertManagerNotifiers(config, group.Group.Name, alertManagerConfig, nil) {
+			fmt.Fprintf(w, "  => receiver %s email %s\n", notifier.getReceiverName(), notifier.Email)
+		}
+	}
+	if _, ok := host.Variables[config.alertmanager.configName]; ok {
+		fmt.Fprintf(w, "  host variable is ignored, only the group variables are used\n")
+	} else if !found {
+		fmt.Fprintf(w, "  no group of the host has %s\n", config.alertmanager.configName)
+	}
+}
+
+/// explainHost Writes where the targets and receivers of the given host come from
+func explainHost(w io.Writer, config Config, model *InventoryModel, host *ModelHost) {
+	groups := model.getHostGroups(host.Host.ID)
+	var groupNames []string
+	for _, group := range groups {
+		groupNames = append(groupNames, group.Group.Name)
+	}
+	fmt.Fprintf(w, "Host %s (id %d, inventory %s)\n", host.Host.Name, host.Host.ID, host.Host.SummaryFields.Inventory.Name)
+	fmt.Fprintf(w, "Groups: %s\n", strings.Join(groupNames, ", "))
+	fmt.Fprintf(w, "The configs are only read from the group and host variables, not from the inventory variables.\n")
+	explainPrometheus(w, config, host, groups)
+	explainBlackbox(w, config, host, groups)
+	explainAlertManager(w, config, host, groups)
+}
+
+/// explain Shows where the targets and receivers of the given host come from
+func explain(args []string) int {
+	flags := newFlagSet("explain", "Shows where the targets and receivers of the given host come from")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: awx-exporter explain [flags] <hostname>\n\n")
+		fmt.Fprintf(flags.Output(), "Shows where the targets and receivers of the given host come from\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	var configPath string
+	var overrides ConfigOverrides
+	addConfigFlags(flags, &configPath, &overrides)
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	config, err := loadConfiguration(configPath, overrides)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	model, err := loadInventoryModel(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "The AWX inventory can not be loaded: %v\n", err)
+		return 1
+	}
+	hosts := model.findHosts(config, flags.Arg(0))
+	if len(hosts) == 0 {
+		fmt.Fprintf(os.Stderr, "No host %s was found\n", flags.Arg(0))
+		return 1
+	}
+	for i, host := range hosts {
+		if i > 0 {
+			fmt.Println()
+		}
+		explainHost(os.Stdout, config, model, host)
+	}
+	return 0
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	inventoryHostsDesc = prometheus.NewDesc(
+		"awx_inventory_hosts",
+		"The number of hosts of the inventory.",
+		[]string{"organization", "inventory", "inventory_id"}, nil)
+	inventoryHostsWithActiveFailuresDesc = prometheus.NewDesc(
+		"awx_inventory_hosts_with_active_failures",
+		"The number of hosts with active failures of the inventory.",
+		[]string{"organization", "inventory", "inventory_id"}, nil)
+	inventorySourcesDesc = prometheus.NewDesc(
+		"awx_inventory_sources",
+		"The number of inventory sources of the inventory.",
+		[]string{"organization", "inventory", "inventory_id"}, nil)
+	inventorySourcesWithFailuresDesc = prometheus.NewDesc(
+		"awx_inventory_sources_with_failures",
+		"The number of failed inventory sources of the inventory.",
+		[]string{"organization", "inventory", "inventory_id"}, nil)
+	groupHostsDesc = prometheus.NewDesc(
+		"awx_group_hosts",
+		"The number of hosts of the group.",
+		[]string{"inventory", "inventory_id", "group"}, nil)
+	groupHostsWithActiveFailuresDesc = prometheus.NewDesc(
+		"awx_group_hosts_with_active_failures",
+		"The number of hosts with active failures of the group.",
+		[]string{"inventory", "inventory_id", "group"}, nil)
+	hostActiveFailuresDesc = prometheus.NewDesc(
+		"awx_host_active_failures",
+		"Whether the host has active failures.",
+		[]string{"inventory", "inventory_id", "host"}, nil)
+	hostEnabledDesc = prometheus.NewDesc(
+		"awx_host_enabled",
+		"Whether the host is enabled.",
+		[]string{"inventory", "inventory_id", "host"}, nil)
+	hostLastJobStatusDesc = prometheus.NewDesc(
+		"awx_host_last_job_status",
+		"The status of the last job of the host, the value is 1 for the current status.",
+		[]string{"inventory", "inventory_id", "host", "job_template", "status"}, nil)
+	hostLastJobFinishedDesc = prometheus.NewDesc(
+		"awx_host_last_job_finished_timestamp_seconds",
+		"The unix timestamp when the last job of the host finished.",
+		[]string{"inventory", "inventory_id", "host", "job_template"}, nil)
+)
+
+/// InventoryCollector exposes the state of the AWX inventories from the last refresh
+type InventoryCollector struct {
+	mutex   sync.RWMutex
+	metrics []prometheus.Metric
+}
+
+/// inventoryCollector is registered in the metrics registry when the inventory metrics are enabled
+var inventoryCollector = &InventoryCollector{}
+
+/// Describe implements the prometheus.Collector interface
+func (collector *InventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- inventoryHostsDesc
+	ch <- inventoryHostsWithActiveFailuresDesc
+	ch <- inventorySourcesDesc
+	ch <- inventorySourcesWithFailuresDesc
+	ch <- groupHostsDesc
+	ch <- groupHostsWithActiveFailuresDesc
+	ch <- hostActiveFailuresDesc
+	ch <- hostEnabledDesc
+	ch <- hostLastJobStatusDesc
+	ch <- hostLastJobFinishedDesc
+}
+
+/// Collect implements the prometheus.Collector interface
+func (collector *InventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	collector.mutex.RLock()
+	defer collector.mutex.RUnlock()
+	for _, metric := range collector.metrics {
+		ch <- metric
+	}
+}
+
+/// boolToFloat Returns 1 for true and 0 for false
+func boolToFloat(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+/// createInventoryMetrics Creates the metrics of the given inventories, groups and hosts. The names are only
+/// unique inside of an inventory or organization, so the inventory id is added to the labels.
+func createInventoryMetrics(inventories []Inventory, groups []Group, hosts []Host) []prometheus.Metric {
+	var metrics []prometheus.Metric
+	// The objects are deduplicated by id, the pages of AWX can overlap while objects are added
+	seenInventories := make(map[int]bool)
+	for _, inventory := range inventories {
+		if seenInventories[inventory.ID] {
+			continue
+		}
+		seenInventories[inventory.ID] = true
+		labels := []string{inventory.SummaryFields.Organization.Name, inventory.Name, strconv.Itoa(inventory.ID)}
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(inventoryHostsDesc, prometheus.GaugeValue, float64(inventory.TotalHosts), labels...),
+			prometheus.MustNewConstMetric(inventoryHostsWithActiveFailuresDesc, prometheus.GaugeValue, float64(inventory.HostsWithActiveFailures), labels...),
+			prometheus.MustNewConstMetric(inventorySourcesDesc, prometheus.GaugeValue, float64(inventory.TotalInventorySources), labels...),
+			prometheus.MustNewConstMetric(inventorySourcesWithFailuresDesc, prometheus.GaugeValue, float64(inventory.InventorySourcesWithFailures), labels...),
+		)
+	}
+	seenGroups := make(map[int]bool)
+	for _, group := range groups {
+		if seenGroups[group.ID] {
+			continue
+		}
+		seenGroups[group.ID] = true
+		inventoryName := group.SummaryFields.Inventory.Name
+		inventoryID := strconv.Itoa(group.SummaryFields.Inventory.ID)
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(groupHostsDesc, prometheus.GaugeValue, float64(group.TotalHosts), inventoryName, inventoryID, group.Name),
+			prometheus.MustNewConstMetric(groupHostsWithActiveFailuresDesc, prometheus.GaugeValue, float64(group.HostsWithActiveFailures), inventoryName, inventoryID, group.Name),
+		)
+	}
+	seenHosts := make(map[int]bool)
+	for _, host := range hosts {
+		if seenHosts[host.ID] {
+			continue
+		}
+		seenHosts[host.ID] = true
+		inventoryName := host.SummaryFields.Inventory.Name
+		inventoryID := strconv.Itoa(host.SummaryFields.Inventory.ID)
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(hostActiveFailuresDesc, prometheus.GaugeValue, boolToFloat(host.HasActiveFailures), inventoryName, inventoryID, host.Name),
+			prometheus.MustNewConstMetric(hostEnabledDesc, prometheus.GaugeValue, boolToFloat(host.Enabled), inventoryName, inventoryID, host.Name),
+		)
+		lastJob := host.SummaryFields.LastJob
+		if lastJob.ID == 0 {
+			continue
+		}
+		metrics = append(metrics,
+			prometheus.MustNewConstMetric(hostLastJobStatusDesc, prometheus.GaugeValue, 1, inventoryName, inventoryID, host.Name, lastJob.JobTemplateName, lastJob.Status))
+		if lastJob.Finished.IsZero() == false {
+			metrics = append(metrics,
+				prometheus.MustNewConstMetric(hostLastJobFinishedDesc, prometheus.GaugeValue, float64(lastJob.Finished.Unix()), inventoryName, inventoryID, host.Name, lastJob.JobTemplateName))
+		}
+	}
+	return metrics
+}
+
+/// getInventoryObjects Returns the groups and hosts of the given source. The inventory model of the syncer is
+/// used as it is, so AWX is only queried for all the groups and hosts without incremental sync.
+func getInventoryObjects(config Config, source InventorySource) ([]Group, []Host, error) {
+	switch source := source.(type) {
+	case *InventoryModel:
+		var groups []Group
+		for _, group := range source.sortedGroups() {
+			groups = append(groups, group.Group)
+		}
+		var hosts []Host
+		for _, host := range source.sortedHosts() {
+			hosts = append(hosts, host.Host)
+		}
+		return groups, hosts, nil
+	case failedSource:
+		return nil, nil, source.err
+	}
+	groups, err := getAllGroups(config, "", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	hosts, err := getAllHosts(config, "", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return groups, hosts, nil
+}
+
+/// refresh Fetches the inventories from AWX, takes the groups and hosts from the given source and replaces
+/// the metrics. On errors the metrics of the last refresh are kept.
+func (collector *InventoryCollector) refresh(config Config, source InventorySource) error {
+	groups, hosts, err := getInventoryObjects(config, source)
+	if err != nil {
+		return err
+	}
+	inventories, err := getAllInventories(config, "", nil)
+	if err != nil {
+		return err
+	}
+	metrics := createInventoryMetrics(inventories, groups, hosts)
+	collector.mutex.Lock()
+	collector.metrics = metrics
+	collector.mutex.Unlock()
+	return nil
+}
+
+/// refreshInventoryMetrics Refreshes the inventory metrics from the given source when they are enabled
+func refreshInventoryMetrics(config Config, source InventorySource) {
+	if config.metrics.inventoryMetrics == false {
+		return
+	}
+	start := time.Now()
+	err := inventoryCollector.refresh(config, source)
+	syncDurationSeconds.WithLabelValues("inventory-metrics").Set(time.Since(start).Seconds())
+	if err != nil {
+		syncFailuresTotal.WithLabelValues("inventory-metrics").Inc()
+		log.Printf("Error refreshing the inventory metrics, keeping the last ones: %v", err)
+		return
+	}
+	lastSuccessTimestampSeconds.WithLabelValues("inventory-metrics").SetToCurrentTime()
+}
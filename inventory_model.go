@@ -0,0 +1,500 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+/// syncOverlap is subtracted from the time of the last sync, so the changes committed during the
+/// last sync and small clock differences between AWX and the exporter are not missed.
+const syncOverlap = time.Minute
+
+/// ModelGroup is a group of the inventory model with its variables and direct hosts
+type ModelGroup struct {
+	Group     Group
+	Variables map[string]interface{}
+	HostIDs   []int
+}
+
+/// ModelHost is a host of the inventory model with its variables and the ansible facts when they are loaded
+type ModelHost struct {
+	Host      Host
+	Variables map[string]interface{}
+	Facts     map[string]interface{}
+}
+
+/// getTemplateVariables Returns the variables the blackbox targets of the host are rendered with
+func (host *ModelHost) getTemplateVariables() map[string]interface{} {
+	return getTemplateVariables(host.Host, host.Variables, host.Facts)
+}
+
+/// InventoryModel is the in-memory copy of the AWX groups and hosts, that is patched by the
+/// incremental syncs
+type InventoryModel struct {
+	groups map[int]*ModelGroup
+	hosts  map[int]*ModelHost
+}
+
+/// newInventoryModel Creates an empty inventory model
+func newInventoryModel() *InventoryModel {
+	return &InventoryModel{
+		groups: make(map[int]*ModelGroup),
+		hosts:  make(map[int]*ModelHost),
+	}
+}
+
+/// getGroup Returns the group with the given id
+func getGroup(config Config, id int) (Group, error) {
+	var group Group
+	err := getAWXResults(config, fmt.Sprintf("groups/%d/", id), false, &group)
+	return group, err
+}
+
+/// getHost Returns the host with the given id
+func getHost(config Config, id int) (Host, error) {
+	var host Host
+	err := getAWXResults(config, fmt.Sprintf("hosts/%d/", id), false, &host)
+	return host, err
+}
+
+/// getAllGroupHosts Returns all the direct hosts of the group by following the pages
+func getAllGroupHosts(config Config, path string, hosts []Host) ([]Host, error) {
+	var results HostResults
+	err := getAWXResults(config, path, true, &results)
+	if err != nil {
+		return hosts, err
+	}
+	hosts = append(hosts, results.Results...)
+	if results.Next == "" {
+		return hosts, nil
+	}
+	return getAllGroupHosts(config, results.Next, hosts)
+}
+
+/// getAllActivityStream Returns all the activity stream entries that match the given search query
+func getAllActivityStream(config Config, searchQuery string, entries []ActivityStreamEntry) ([]ActivityStreamEntry, error) {
+	var results ActivityStreamResults
+	err := getAWXResults(config, fmt.Sprintf("activity_stream/?%s", searchQuery), false, &results)
+	if err != nil {
+		return entries, err
+	}
+	entries = append(entries, results.Results...)
+	nextPageQuery, err := getNextPageQuery(results.Next)
+	if err != nil || nextPageQuery == "" {
+		return entries, err
+	}
+	return getAllActivityStream(config, nextPageQuery, entries)
+}
+
+/// loadInventoryModel Loads all the groups and hosts with their variables from AWX
+func loadInventoryModel(config Config) (*InventoryModel, error) {
+	model := newInventoryModel()
+	groups, err := getAllGroups(config, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		err = model.setGroup(config, group)
+		if err != nil {
+			return nil, err
+		}
+	}
+	hosts, err := getAllHosts(config, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range hosts {
+		err = model.setHost(config, host)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return model, nil
+}
+
+/// setGroup Adds or replaces the given group with its variables and hosts
+func (model *InventoryModel) setGroup(config Config, group Group) error {
+	variables, err := getGroupVariables(config, group)
+	if err != nil {
+		return err
+	}
+	hosts, err := getAllGroupHosts(config, group.Related.Hosts, nil)
+	if err != nil {
+		return err
+	}
+	modelGroup := &ModelGroup{Group: group, Variables: variables}
+	for _, host := range hosts {
+		modelGroup.HostIDs = append(modelGroup.HostIDs, host.ID)
+	}
+	model.groups[group.ID] = modelGroup
+	return nil
+}
+
+/// setHost Adds or replaces the given host with its variables and facts
+func (model *InventoryModel) setHost(config Config, host Host) error {
+	variables, err := getHostVariables(config, host)
+	if err != nil {
+		return err
+	}
+	modelHost := &ModelHost{Host: host, Variables: variables}
+	if config.blackbox.loadFacts {
+		modelHost.Facts, err = getHostFacts(config, host)
+		if err != nil {
+			return err
+		}
+	}
+	model.hosts[host.ID] = modelHost
+	return nil
+}
+
+/// setHostJob Replaces the last job and the failures of the host, which are used by the inventory metrics. The
+/// variables are kept, the hosts that are not in the model are added by the next sync.
+func (model *InventoryModel) setHostJob(host Host) {
+	modelHost, ok := model.hosts[host.ID]
+	if !ok {
+		return
+	}
+	modelHost.Host.HasActiveFailures = host.HasActiveFailures
+	modelHost.Host.LastJob = host.LastJob
+	modelHost.Host.LastJobHostSummary = host.LastJobHostSummary
+	modelHost.Host.SummaryFields.LastJob = host.SummaryFields.LastJob
+	modelHost.Host.SummaryFields.LastJobHostSummary = host.SummaryFields.LastJobHostSummary
+	modelHost.Host.SummaryFields.RecentJobs = host.SummaryFields.RecentJobs
+}
+
+/// reloadGroup Fetches the group with the given id again, it is removed when it does not exist anymore
+func (model *InventoryModel) reloadGroup(config Config, id int) error {
+	group, err := getGroup(config, id)
+	if errors.Is(err, errNotFound) {
+		model.removeGroup(id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return model.setGroup(config, group)
+}
+
+/// reloadHost Fetches the host with the given id again, it is removed when it does not exist anymore
+func (model *InventoryModel) reloadHost(config Config, id int) error {
+	host, err := getHost(config, id)
+	if errors.Is(err, errNotFound) {
+		model.removeHost(id)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return model.setHost(config, host)
+}
+
+/// removeGroup Removes the group with the given id
+func (model *InventoryModel) removeGroup(id int) {
+	delete(model.groups, id)
+}
+
+/// removeHost Removes the host with the given id and its group memberships
+func (model *InventoryModel) removeHost(id int) {
+	delete(model.hosts, id)
+	for _, group := range model.groups {
+		hostIDs := group.HostIDs[:0]
+		for _, hostID := range group.HostIDs {
+			if hostID != id {
+				hostIDs = append(hostIDs, hostID)
+			}
+		}
+		group.HostIDs = hostIDs
+	}
+}
+
+/// getChangedObjectID Returns the id of the object of a create, update or delete entry
+func getChangedObjectID(entry ActivityStreamEntry) (int, bool) {
+	switch id := entry.Changes["id"].(type) {
+	case float64:
+		return int(id), true
+	case string:
+		var parsed int
+		_, err := fmt.Sscanf(id, "%d", &parsed)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+/// update Patches the model with the groups and hosts that were modified since the given time and
+/// the deletions and membership changes of the activity stream.
+func (model *InventoryModel) update(config Config, since time.Time) error {
+	modifiedQuery := "modified__gt=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	groups, err := getAllGroups(config, modifiedQuery, nil)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		err = model.setGroup(config, group)
+		if err != nil {
+			return err
+		}
+	}
+	hosts, err := getAllHosts(config, modifiedQuery, nil)
+	if err != nil {
+		return err
+	}
+	if config.blackbox.loadFacts {
+		// The facts do not change the modification time of the hosts
+		factsQuery := "ansible_facts_modified__gt=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+		factsHosts, err := getAllHosts(config, factsQuery, nil)
+		if err != nil {
+			return err
+		}
+		hosts = append(hosts, factsHosts...)
+	}
+	for _, host := range hosts {
+		err = model.setHost(config, host)
+		if err != nil {
+			return err
+		}
+	}
+	// The job runs do not change the modification time of the hosts either
+	jobQuery := "last_job__finished__gt=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	jobHosts, err := getAllHosts(config, jobQuery, nil)
+	if err != nil {
+		return err
+	}
+	for _, host := range jobHosts {
+		model.setHostJob(host)
+	}
+	entries, err := getAllActivityStream(
+		config,
+		"object1__in=host,group&order_by=id&timestamp__gt="+url.QueryEscape(since.UTC().Format(time.RFC3339)),
+		nil)
+	if err != nil {
+		return err
+	}
+	changedGroups := make(map[int]bool)
+	changedHosts := make(map[int]bool)
+	for _, entry := range entries {
+		switch entry.Operation {
+		case "delete":
+			id, ok := getChangedObjectID(entry)
+			if !ok {
+				continue
+			}
+			if entry.Object1 == "host" {
+				changedHosts[id] = true
+			} else if entry.Object1 == "group" {
+				changedGroups[id] = true
+			}
+		case "associate", "disassociate":
+			// The memberships do not change the modification time of the groups and the hosts
+			for _, group := range entry.SummaryFields.Group {
+				changedGroups[group.ID] = true
+			}
+			for _, host := range entry.SummaryFields.Host {
+				changedHosts[host.ID] = true
+			}
+		}
+	}
+	for id := range changedGroups {
+		err = model.reloadGroup(config, id)
+		if err != nil {
+			return err
+		}
+	}
+	for id := range changedHosts {
+		err = model.reloadHost(config, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/// sortedGroups Returns the groups of the model sorted by their name and id
+func (model *InventoryModel) sortedGroups() []*ModelGroup {
+	var groups []*ModelGroup
+	for _, group := range model.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return lessGroup(groups[i].Group, groups[j].Group)
+	})
+	return groups
+}
+
+/// sortedHosts Returns the hosts of the model sorted by their name and id
+func (model *InventoryModel) sortedHosts() []*ModelHost {
+	var hosts []*ModelHost
+	for _, host := range model.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Host.Name != hosts[j].Host.Name {
+			return hosts[i].Host.Name < hosts[j].Host.Name
+		}
+		return hosts[i].Host.ID < hosts[j].Host.ID
+	})
+	return hosts
+}
+
+/// PrometheusHosts Returns the prometheus hosts of the groups with prometheus configuration
+func (model *InventoryModel) PrometheusHosts(config Config) ([]PrometheusHost, error) {
+	prometheusHosts := []PrometheusHost{}
+	for _, group := range model.sortedGroups() {
+		prometheusConfig, ok := group.Variables[config.prometheus.configName]
+		if !ok {
+			continue
+		}
+		for _, hostID := range group.HostIDs {
+			host, ok := model.hosts[hostID]
+			if !ok {
+				continue
+			}
+			prometheusHosts = createPrometheusHosts(config, group.Group.Name, host.Variables, prometheusConfig, prometheusHosts)
+		}
+	}
+	return prometheusHosts, nil
+}
+
+/// getBlackboxGroupConfigs Returns the blackbox configs of the given groups
+func getBlackboxGroupConfigs(config Config, groups []*ModelGroup) []BlackboxGroupConfig {
+	var groupConfigs []BlackboxGroupConfig
+	for _, group := range groups {
+		groupConfig := BlackboxGroupConfig{
+			Group:   group.Group.Name,
+			Config:  group.Variables[config.blackbox.configName],
+			Probers: group.Variables[config.blackbox.probersConfigName],
+		}
+		if groupConfig.Config != nil || groupConfig.Probers != nil {
+			groupConfigs = append(groupConfigs, groupConfig)
+		}
+	}
+	return groupConfigs
+}
+
+/// BlackboxHosts Returns the blackbox hosts of the hosts with blackbox configuration or in groups with one
+func (model *InventoryModel) BlackboxHosts(config Config) ([]BlackboxHost, error) {
+	blackboxHosts := []BlackboxHost{}
+	hostGroups := model.getHostGroupIndex()
+	for _, host := range model.sortedHosts() {
+		groupConfigs := getBlackboxGroupConfigs(config, hostGroups[host.Host.ID])
+		blackboxHosts = createHostBlackboxHosts(config, host.Host, getModelGroupNames(hostGroups[host.Host.ID]), host.getTemplateVariables(), groupConfigs, blackboxHosts)
+	}
+	return blackboxHosts, nil
+}
+
+/// AlertManagerNotifiers Returns the notifiers of the groups with alertmanager configuration
+func (model *InventoryModel) AlertManagerNotifiers(config Config) ([]AlertManagerEmailNotifier, error) {
+	var notifiers []AlertManagerEmailNotifier
+	for _, group := range model.sortedGroups() {
+		if alertManagerConfig, ok := group.Variables[config.alertmanager.configName]; ok {
+			notifiers = createAlertManagerNotifiers(config, group.Group.Name, alertManagerConfig, notifiers)
+		}
+	}
+	return notifiers, nil
+}
+
+/// BlackboxModules Returns the blackbox modules of the groups with modules configuration
+func (model *InventoryModel) BlackboxModules(config Config) ([]BlackboxModule, error) {
+	var modules []BlackboxModule
+	for _, group := range model.sortedGroups() {
+		if modulesConfig, ok := group.Variables[config.blackbox.modulesConfigName]; ok {
+			modules = createBlackboxModules(config, group.Group.Name, modulesConfig, modules)
+		}
+	}
+	return modules, nil
+}
+
+/// InventorySyncer keeps the inventory model of the watch and server modes up to date
+type InventorySyncer struct {
+	mutex    sync.Mutex
+	model    *InventoryModel
+	lastSync time.Time
+	lastFull time.Time
+}
+
+/// newInventorySyncer Creates a syncer without a model, the first sync is a full one
+func newInventorySyncer() *InventorySyncer {
+	return &InventorySyncer{}
+}
+
+/// source Returns the source the outputs should be created from. Without incremental sync AWX is
+/// queried directly, otherwise the model is synced with AWX first. On errors the returned source
+/// fails, so the last known good results are used.
+func (syncer *InventorySyncer) source(config Config) InventorySource {
+	if config.sync.incremental == false {
+		return awxSource{}
+	}
+	err := syncer.sync(config)
+	if err != nil {
+		log.Printf("Error syncing the AWX inventory: %v", err)
+		return failedSource{err: err}
+	}
+	return syncer.model
+}
+
+/// sync Loads the complete model when there is none or the full sync interval has passed, otherwise
+/// only the changes since the last sync are applied. A failed incremental sync forces a full one.
+func (syncer *InventorySyncer) sync(config Config) error {
+	syncer.mutex.Lock()
+	defer syncer.mutex.Unlock()
+	start := time.Now()
+	if syncer.model == nil || time.Since(syncer.lastFull) >= config.sync.fullSyncInterval {
+		model, err := loadInventoryModel(config)
+		inventorySyncsTotal.WithLabelValues("full", getSyncResult(err)).Inc()
+		if err != nil {
+			return fmt.Errorf("the full sync failed: %w", err)
+		}
+		syncer.model = model
+		syncer.lastFull = start
+		syncer.lastSync = start
+		return nil
+	}
+	err := syncer.model.update(config, syncer.lastSync.Add(-syncOverlap))
+	inventorySyncsTotal.WithLabelValues("incremental", getSyncResult(err)).Inc()
+	if err != nil {
+		// The model may be patched partially, so it is loaded completely on the next sync
+		syncer.model = nil
+		return fmt.Errorf("the incremental sync failed: %w", err)
+	}
+	syncer.lastSync = start
+	return nil
+}
+
+/// getSyncResult Returns the result label of a sync
+func getSyncResult(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+/// getHostGroupIndex Returns the sorted groups of every host it is a direct member of
+func (model *InventoryModel) getHostGroupIndex() map[int][]*ModelGroup {
+	index := make(map[int][]*ModelGroup)
+	for _, group := range model.sortedGroups() {
+		for _, id := range group.HostIDs {
+			index[id] = append(index[id], group)
+		}
+	}
+	return index
+}
+
+/// getHostGroups Returns the groups the host with the given id is a direct member of
+func (model *InventoryModel) getHostGroups(hostID int) []*ModelGroup {
+	return model.getHostGroupIndex()[hostID]
+}
+
+/// findHosts Returns the hosts with the given AWX name or host name variable
+func (model *InventoryModel) findHosts(config Config, name string) []*ModelHost {
+	var hosts []*ModelHost
+	for _, host := range model.sortedHosts() {
+		if host.Host.Name == name || fmt.Sprintf("%v", host.Variables[config.prometheus.HostNameVar]) == name {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,70 @@
+package main
+
+/// InventorySource provides the hosts and notifiers the outputs are created from
+type InventorySource interface {
+	PrometheusHosts(config Config) ([]PrometheusHost, error)
+	BlackboxHosts(config Config) ([]BlackboxHost, error)
+	AlertManagerNotifiers(config Config) ([]AlertManagerEmailNotifier, error)
+	BlackboxModules(config Config) ([]BlackboxModule, error)
+}
+
+/// awxSource queries AWX directly for every output
+type awxSource struct{}
+
+/// PrometheusHosts Returns the prometheus hosts by traversing the AWX groups
+func (source awxSource) PrometheusHosts(config Config) ([]PrometheusHost, error) {
+	return createPrometheusConfig(config, "", []PrometheusHost{})
+}
+
+/// BlackboxHosts Returns the blackbox hosts by querying the AWX hosts with blackbox configuration
+func (source awxSource) BlackboxHosts(config Config) ([]BlackboxHost, error) {
+	return createBlackboxConfig(config)
+}
+
+/// AlertManagerNotifiers Returns the notifiers by querying the AWX groups with alertmanager configuration
+func (source awxSource) AlertManagerNotifiers(config Config) ([]AlertManagerEmailNotifier, error) {
+	return getAlertManagerNotifiers(config, "", nil)
+}
+
+/// BlackboxModules Returns the blackbox modules by querying the AWX groups with modules configuration
+func (source awxSource) BlackboxModules(config Config) ([]BlackboxModule, error) {
+	return getBlackboxModules(config)
+}
+
+/// failedSource returns the error of a failed inventory sync for every output
+type failedSource struct {
+	err error
+}
+
+/// PrometheusHosts Returns the error of the sync
+func (source failedSource) PrometheusHosts(config Config) ([]PrometheusHost, error) {
+	return nil, source.err
+}
+
+/// BlackboxHosts Returns the error of the sync
+func (source failedSource) BlackboxHosts(config Config) ([]BlackboxHost, error) {
+	return nil, source.err
+}
+
+/// AlertManagerNotifiers Returns the error of the sync
+func (source failedSource) AlertManagerNotifiers(config Config) ([]AlertManagerEmailNotifier, error) {
+	return nil, source.err
+}
+
+/// BlackboxModules Returns the error of the sync
+func (source failedSource) BlackboxModules(config Config) ([]BlackboxModule, error) {
+	return nil, source.err
+}
+
+/// loadSource Returns the source of a one shot run. With several modes the inventory is loaded once,
+/// so all the outputs are created from the same snapshot. A single mode queries only what it needs.
+func loadSource(config Config, modes []Mode) (InventorySource, error) {
+	if len(modes) < 2 {
+		return awxSource{}, nil
+	}
+	model, err := loadInventoryModel(config)
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
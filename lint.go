@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/// lintConfiguration Returns the problems of the configuration and the source files
+func lintConfiguration(config Config) []string {
+	var problems []string
+	if config.awx.Host == "" {
+		problems = append(problems, "AWX.HostName is not set")
+	}
+	for key, value := range map[string]string{
+		"PROMETHEUS.ConfigName":   config.prometheus.configName,
+		"BLACKBOX.ConfigName":     config.blackbox.configName,
+		"ALERTMANAGER.ConfigName": config.alertmanager.configName,
+	} {
+		if value == "" {
+			problems = append(problems, fmt.Sprintf("%s is not set", key))
+		}
+	}
+	if config.alertmanager.sourceFile != "" {
+		if _, _, err := readAlertManagerConfig(config); err != nil {
+			problems = append(problems, fmt.Sprintf("ALERTMANAGER.SourceFile can not be read: %v", err))
+		}
+	}
+	if config.prometheus.sourceFile != "" {
+		if _, err := readPrometheusConfig(config); err != nil {
+			problems = append(problems, fmt.Sprintf("PROMETHEUS.SourceFile can not be read: %v", err))
+		}
+	}
+	if config.blackbox.modulesSourceFile != "" {
+		if _, err := readBlackboxConfig(config); err != nil {
+			problems = append(problems, fmt.Sprintf("BLACKBOX.ModulesSourceFile can not be read: %v", err))
+		}
+	}
+	for _, mode := range getModes() {
+		outputFile := mode.OutputFile(config)
+		if outputFile == "" {
+			continue
+		}
+		if info, err := os.Stat(filepath.Dir(outputFile)); err != nil || info.IsDir() == false {
+			problems = append(problems, fmt.Sprintf("The directory of the %s output file %s does not exist", mode.Name, outputFile))
+		}
+	}
+	if (config.server.tlsCertFile == "") != (config.server.tlsKeyFile == "") {
+		problems = append(problems, "SERVER.TLSCertFile and SERVER.TLSKeyFile should be set together")
+	}
+	return problems
+}
+
+/// lintInventory Creates the hosts and notifiers from the AWX inventory and returns the problems
+func lintInventory(config Config) []string {
+	model, err := loadInventoryModel(config)
+	if err != nil {
+		return []string{fmt.Sprintf("The AWX inventory can not be loaded: %v", err)}
+	}
+	return lintModel(config, model)
+}
+
+/// lintModel Creates the hosts and notifiers from the inventory model and returns the skipped invalid entries,
+/// like the invalid blackbox targets, and the other problems
+func lintModel(config Config, model *InventoryModel) []string {
+	var problems []string
+	skipped := collectValidationErrors(func() {
+		_, _ = model.PrometheusHosts(config)
+		_, _ = model.AlertManagerNotifiers(config)
+		if config.blackbox.modulesSourceFile != "" {
+			if _, err := createBlackboxFileConfig(config, model); err != nil {
+				problems = append(problems, fmt.Sprintf("The blackbox config can not be created: %v", err))
+			}
+		} else {
+			_, _ = model.BlackboxHosts(config)
+		}
+		for _, probe := range config.probe.probes {
+			_, _ = model.BlackboxHosts(getProbeConfig(config, probe))
+		}
+	})
+	for _, reason := range skipped {
+		problems = append(problems, fmt.Sprintf("Skipped an invalid entry of %s", reason))
+	}
+	return problems
+}
+
+/// lint Checks the configuration, the source files and the AWX variables
+func lint(args []string) int {
+	flags := newFlagSet("lint", "Checks the configuration, the source files and the AWX variables")
+	var configPath string
+	var overrides ConfigOverrides
+	addConfigFlags(flags, &configPath, &overrides)
+	configOnly := flags.Bool("config-only", false, "Only checks the configuration and the source files, AWX is not queried")
+	if exitCode, ok := parseFlags(flags, args); !ok {
+		return exitCode
+	}
+	config, err := loadConfiguration(configPath, overrides)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	problems := lintConfiguration(config)
+	if *configOnly == false {
+		problems = append(problems, lintInventory(config)...)
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("%d problems found\n", len(problems))
+		return 1
+	}
+	fmt.Println("No problems found")
+	return 0
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"encoding/json"
 	"errors"
-	"flag"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +10,8 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	altMgrConfig "github.com/uniwue-rz/awx-exporter/alertmanager/config"
@@ -25,22 +26,55 @@ type AWXConfig struct {
 	InventorySources []string
 	Token            string
 	Timeout          time.Duration
+	Retries          int
+	RetryDelay       time.Duration
 }
 
 /// PrometheusConfig is used for the keys of the variables that contain the prometheus config
 type PrometheusConfig struct {
-	configName         string
-	configHostOverride bool
-	HostNameVar        string
-	IpVar              string
+	configName             string
+	configHostOverride     bool
+	HostNameVar            string
+	IpVar                  string
+	scrapeTargetMode       string
+	fileSDPath             string
+	sourceFile             string
+	jobPrefix              string
+	outputFile             string
+	scrapeConfigOutputFile string
+	configOutputFile       string
 }
 
 /// BlackboxConfig contains the config name for the black box
 type BlackboxConfig struct {
-	configName    string
-	IgnoredGroups []string
-	HostNameVar   string
-	IpVar         string
+	configName             string
+	IgnoredGroups          []string
+	HostNameVar            string
+	IpVar                  string
+	exporterAddress        string
+	fileSDPath             string
+	outputFile             string
+	loadFacts              bool
+	groupStrategy          string
+	preferredGroups        []string
+	fallbackGroup          string
+	modulesConfigName      string
+	modulesSourceFile      string
+	modulesOutputFile      string
+	jobName                string
+	scrapeJobs             string
+	scrapeConfigOutputFile string
+	probersConfigName      string
+	defaultProbers         []string
+	probers                map[string]string
+	tlsVariables           []string
+	tlsModule              string
+	tlsPort                int
+	metricsPath            string
+	params                 []string
+	labels                 map[string]string
+	resolveTargets         bool
+	moduleProbers          map[string]string
 }
 
 /// AlertManagerConfig contains the config name for the Alertmanager
@@ -49,6 +83,43 @@ type AlertManagerConfig struct {
 	sourceFile  string
 	sendResolve bool
 	requireTls  bool
+	outputFile  string
+}
+
+/// ServerConfig contains the settings of the http service discovery server
+type ServerConfig struct {
+	listenAddress   string
+	refreshInterval time.Duration
+	tlsCertFile     string
+	tlsKeyFile      string
+}
+
+/// WebhookConfig contains the settings of the AWX notification endpoint
+type WebhookConfig struct {
+	secret   string
+	debounce time.Duration
+}
+
+/// SyncConfig contains the settings of the inventory sync of the watch and server modes
+type SyncConfig struct {
+	incremental      bool
+	fullSyncInterval time.Duration
+}
+
+/// MetricsConfig contains the settings of the exported metrics
+type MetricsConfig struct {
+	inventoryMetrics bool
+}
+
+/// StateConfig contains the directory where the last known good results are kept
+type StateConfig struct {
+	directory string
+}
+
+/// SafetyGuardConfig contains the allowed drop of the targets, receivers and routes between two outputs
+type SafetyGuardConfig struct {
+	maxDropPercent  float64
+	maxDropAbsolute int
 }
 
 /// Creates the config object that should be used for the application
@@ -57,8 +128,18 @@ type Config struct {
 	prometheus   PrometheusConfig
 	blackbox     BlackboxConfig
 	alertmanager AlertManagerConfig
+	server       ServerConfig
+	safetyGuard  SafetyGuardConfig
+	state        StateConfig
+	metrics      MetricsConfig
+	webhook      WebhookConfig
+	sync         SyncConfig
+	probe        ProbesConfig
 }
 
+/// errNotFound is returned when the requested AWX object does not exist
+var errNotFound = errors.New("the AWX object does not exist")
+
 /// Creates a new AWX request that can be used for the query
 func createAuthenticateAWXRequest(config Config, path string, method string, body io.Reader, withoutPrefix bool) (*http.Request, error) {
 	fullUrl := fmt.Sprintf("%s/api/v2/%s", config.awx.Host, path)
@@ -67,142 +148,207 @@ func createAuthenticateAWXRequest(config Config, path string, method string, bod
 	}
 	req, err := http.NewRequest(method, fullUrl, body)
 	if err != nil {
-		log.Fatal("Error creating the request", err)
+		return nil, fmt.Errorf("error creating the request: %w", err)
 	}
 	bearerToken := fmt.Sprintf("Bearer %s", config.awx.Token)
 	req.Header.Set("Authorization", bearerToken)
-	return req, err
+	return req, nil
 }
 
 /// sendRequest Sends the request with the given configuration
-func sendRequest(r *http.Request, err error, config Config) *http.Response {
+func sendRequest(r *http.Request, err error, config Config) (*http.Response, error) {
+	if err != nil {
+		return nil, err
+	}
 	client := http.Client{Timeout: config.awx.Timeout}
 	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		for key, val := range via[0].Header {
 			req.Header[key] = val
 		}
-		return err
+		return nil
 	}
 	data, err := client.Do(r)
 	if err != nil {
-		log.Fatal("Error sending the request", err)
+		return nil, fmt.Errorf("error sending the request: %w", err)
+	}
+	return data, nil
+}
+
+/// getAWXResults Sends a GET request to the given AWX path and decodes the results. The request is
+/// retried on connection errors and server errors.
+func getAWXResults(config Config, path string, withoutPrefix bool, results interface{}) error {
+	endpoint := getEndpointLabel(path)
+	var response *http.Response
+	var err error
+	for attempt := 0; attempt <= config.awx.Retries; attempt++ {
+		if attempt > 0 {
+			awxRequestRetriesTotal.WithLabelValues(endpoint).Inc()
+			time.Sleep(time.Duration(attempt) * config.awx.RetryDelay)
+		}
+		request, requestErr := createAuthenticateAWXRequest(config, path, "GET", nil, withoutPrefix)
+		response, err = sendRequest(request, requestErr, config)
+		if err != nil {
+			awxRequestsTotal.WithLabelValues(endpoint, "error").Inc()
+			continue
+		}
+		awxRequestsTotal.WithLabelValues(endpoint, strconv.Itoa(response.StatusCode)).Inc()
+		if response.StatusCode >= 500 {
+			response.Body.Close()
+			err = fmt.Errorf("server returns error status %d for %s", response.StatusCode, path)
+			continue
+		}
+		break
+	}
+	if err != nil {
+		return err
 	}
-	return data
+	defer response.Body.Close()
+	if response.StatusCode == 404 {
+		return fmt.Errorf("%w: %s", errNotFound, path)
+	}
+	if response.StatusCode != 200 {
+		return fmt.Errorf("server returns error status %d for %s", response.StatusCode, path)
+	}
+	decoder := json.NewDecoder(response.Body)
+	err = decoder.Decode(results)
+	if err != nil {
+		return fmt.Errorf("there was an error decoding the results of %s: %w", path, err)
+	}
+	return nil
 }
 
 /// getInventories Returns the inventory query results
-func getInventories(config Config, inventoryName string) InventoryResult {
+func getInventories(config Config, inventoryName string) (InventoryResult, error) {
 	var path string
 	if inventoryName != "" {
 		path = fmt.Sprintf("inventories?name=%s", inventoryName)
 	} else {
 		path = "inventories"
 	}
-	request, err := createAuthenticateAWXRequest(config, path, "GET", nil, false)
-	response := sendRequest(request, err, config)
-	if response.StatusCode != 200 {
-		log.Fatalf("Server returns error status %d", response.StatusCode)
-	}
-	decoder := json.NewDecoder(response.Body)
 	var results InventoryResult
-	err = decoder.Decode(&results)
-	if err != nil {
-		log.Fatal("There was an error decoding the results", err)
-	}
-	return results
+	err := getAWXResults(config, path, false, &results)
+	return results, err
 }
 
 /// Returns the group that match the given search query
-func getGroups(config Config, searchQuery string) GroupResults {
+func getGroups(config Config, searchQuery string) (GroupResults, error) {
 	var path string
 	if searchQuery != "" {
 		path = fmt.Sprintf("groups/?%s", searchQuery)
 	} else {
 		path = "groups"
 	}
-	request, err := createAuthenticateAWXRequest(config, path, "GET", nil, false)
-	response := sendRequest(request, err, config)
-	if response.StatusCode != 200 {
-		log.Fatalf("Server returns error status %d", response.StatusCode)
-	}
-	decoder := json.NewDecoder(response.Body)
 	var results GroupResults
-	err = decoder.Decode(&results)
-	if err != nil {
-		log.Fatal("There was an error decoding the results", err)
-	}
-	return results
+	err := getAWXResults(config, path, false, &results)
+	return results, err
 }
 
 /// getHosts Returns the hosts that match the given query string
-func getHosts(config Config, searchQuery string) HostResults {
+func getHosts(config Config, searchQuery string) (HostResults, error) {
 	var path string
 	if searchQuery != "" {
 		path = fmt.Sprintf("hosts/?%s", searchQuery)
 	} else {
 		path = "hosts"
 	}
-	request, err := createAuthenticateAWXRequest(config, path, "GET", nil, false)
-	response := sendRequest(request, err, config)
-	if response.StatusCode != 200 {
-		log.Fatalf("Server returns error status %d", response.StatusCode)
-	}
-	decoder := json.NewDecoder(response.Body)
 	var results HostResults
-	err = decoder.Decode(&results)
+	err := getAWXResults(config, path, false, &results)
+	return results, err
+}
+
+///getHostVariables Returns the host data that should be used.
+func getHostVariables(config Config, host Host) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	err := getAWXResults(config, host.Related.VariableData, true, &vars)
+	return vars, err
+}
+
+/// getHostFacts Returns the ansible facts of the given host
+func getHostFacts(config Config, host Host) (map[string]interface{}, error) {
+	facts := make(map[string]interface{})
+	err := getAWXResults(config, host.Related.AnsibleFacts, true, &facts)
+	return facts, err
+}
+
+/// getGroupVariables Returns the group variables for the
+func getGroupVariables(config Config, group Group) (map[string]interface{}, error) {
+	vars := make(map[string]interface{})
+	err := getAWXResults(config, group.Related.VariableData, true, &vars)
+	return vars, err
+}
+
+/// getAllInventories Returns all the inventories by following the pages
+func getAllInventories(config Config, nextPage string, inventories []Inventory) ([]Inventory, error) {
+	path := "inventories"
+	if nextPage != "" {
+		path = fmt.Sprintf("inventories/?%s", nextPage)
+	}
+	var results InventoryResult
+	err := getAWXResults(config, path, false, &results)
 	if err != nil {
-		log.Fatal("There was an error decoding the results", err)
+		return inventories, err
 	}
-	return results
+	inventories = append(inventories, results.Results...)
+	nextPageQuery, err := getNextPageQuery(results.Next)
+	if err != nil || nextPageQuery == "" {
+		return inventories, err
+	}
+	return getAllInventories(config, nextPageQuery, inventories)
 }
 
-/// getGroupsHosts Returns the hosts that belong to a given group
-func getGroupHost(config Config, group Group) HostResults {
-	request, err := createAuthenticateAWXRequest(config, group.Related.Hosts, "GET", nil, true)
-	response := sendRequest(request, err, config)
-	decoder := json.NewDecoder(response.Body)
-	var results HostResults
-	err = decoder.Decode(&results)
+/// getAllGroups Returns all the groups that match the given search query by following the pages
+func getAllGroups(config Config, searchQuery string, groups []Group) ([]Group, error) {
+	results, err := getGroups(config, searchQuery)
 	if err != nil {
-		log.Fatal("There was an error decoding the results", err)
+		return groups, err
+	}
+	groups = append(groups, results.Results...)
+	nextPageQuery, err := getNextPageQuery(results.Next)
+	if err != nil || nextPageQuery == "" {
+		return groups, err
 	}
-	return results
+	return getAllGroups(config, nextPageQuery, groups)
 }
 
-///getHostVariables Returns the host data that should be used.
-func getHostVariables(config Config, host Host) map[string]interface{} {
-	vars := make(map[string]interface{})
-	request, err := createAuthenticateAWXRequest(
-		config,
-		host.Related.VariableData,
-		"GET",
-		nil,
-		true)
-	response := sendRequest(request, err, config)
-	decoder := json.NewDecoder(response.Body)
-	err = decoder.Decode(&vars)
+/// lessGroup Returns whether the left group is ordered before the right one by their name and id
+func lessGroup(left Group, right Group) bool {
+	if left.Name != right.Name {
+		return left.Name < right.Name
+	}
+	return left.ID < right.ID
+}
+
+/// sortGroups Sorts the groups in the same order as the groups of the inventory model
+func sortGroups(groups []Group) {
+	sort.Slice(groups, func(i, j int) bool {
+		return lessGroup(groups[i], groups[j])
+	})
+}
+
+/// getAllHosts Returns all the hosts that match the given search query by following the pages
+func getAllHosts(config Config, searchQuery string, hosts []Host) ([]Host, error) {
+	results, err := getHosts(config, searchQuery)
 	if err != nil {
-		log.Fatal("There was an error decoding the results", err)
+		return hosts, err
+	}
+	hosts = append(hosts, results.Results...)
+	nextPageQuery, err := getNextPageQuery(results.Next)
+	if err != nil || nextPageQuery == "" {
+		return hosts, err
 	}
-	return vars
+	return getAllHosts(config, nextPageQuery, hosts)
 }
 
-/// getGroupVariables Returns the group variables for the
-func getGroupVariables(config Config, group Group) map[string]interface{} {
-	vars := make(map[string]interface{})
-	request, err := createAuthenticateAWXRequest(
-		config,
-		group.Related.VariableData,
-		"GET",
-		nil,
-		true)
-	response := sendRequest(request, err, config)
-	decoder := json.NewDecoder(response.Body)
-	err = decoder.Decode(&vars)
+/// getNextPageQuery Returns the query of the next page or an empty string when there is none
+func getNextPageQuery(next string) (string, error) {
+	if next == "" {
+		return "", nil
+	}
+	parsedUrl, err := url.Parse(next)
 	if err != nil {
-		log.Fatal("There was an error decoding the results", err)
+		return "", fmt.Errorf("the given url can not be parsed: %w", err)
 	}
-	return vars
+	return parsedUrl.RawQuery, nil
 }
 
 ///createPrometheusHosts Creates the host nodes that can be directly extracted as prometheus configurations
@@ -217,7 +363,7 @@ func createPrometheusHosts(
 	if hostPrometheusConfig, ok := hostVariables[config.prometheus.configName]; ok && config.prometheus.configHostOverride {
 		prometheusConfig = hostPrometheusConfig
 	}
-	for _, promSingleNode := range prometheusConfig.([]interface{}) {
+	for _, promSingleNode := range getConfigEntries(config.prometheus.configName, prometheusConfig) {
 		prometheusHost := PrometheusHost{}
 		labels := PrometheusHostLabel{}
 		var targets []string
@@ -228,13 +374,14 @@ func createPrometheusHosts(
 			labels.Host = fmt.Sprintf("%v", hostNameVar)
 		}
 		labels.Group = group
-		if prometheusJobName, ok := promSingleNode.(map[string]interface{})["name"]; ok {
+		if prometheusJobName, ok := promSingleNode["name"]; ok {
 			labels.Job = fmt.Sprintf("%v", prometheusJobName)
 		}
-		if prometheusPort, ok := promSingleNode.(map[string]interface{})["port"]; ok {
+		if prometheusPort, ok := promSingleNode["port"]; ok {
 			target := fmt.Sprintf("%s:%.0f", labels.IP, prometheusPort)
 			targets = append(targets, target)
 		}
+		prometheusHost.Scrape = getPrometheusScrapeSettings(promSingleNode)
 		prometheusHost.Labels = labels
 		prometheusHost.Targets = targets
 		prometheusHosts = append(prometheusHosts, prometheusHost)
@@ -242,42 +389,169 @@ func createPrometheusHosts(
 	return prometheusHosts
 }
 
-///createPrometheusConfig Creates the Prometheus config
-func createPrometheusConfig(config Config, nextPage string, prometheusHosts []PrometheusHost) []PrometheusHost {
-	allGroups := GroupResults{}
-	if nextPage == "" {
-		allGroups = getGroups(config, "")
-	} else {
-		allGroups = getGroups(config, nextPage)
-	}
-	if allGroups.Count > 0 {
-		for _, group := range allGroups.Results {
-			groupVariables := getGroupVariables(config, group)
-			if prometheusConfig, ok := groupVariables[config.prometheus.configName]; ok {
-				hosts := getGroupHost(config, group)
-				for _, host := range hosts.Results {
-					hostVariables := getHostVariables(config, host)
-					prometheusHosts = createPrometheusHosts(config, group.Name, hostVariables, prometheusConfig, prometheusHosts)
+///createPrometheusConfig Creates the Prometheus config from all the groups and all the pages of their hosts,
+/// the groups are traversed in the order of the inventory model
+func createPrometheusConfig(config Config, searchQuery string, prometheusHosts []PrometheusHost) ([]PrometheusHost, error) {
+	groups, err := getAllGroups(config, searchQuery, nil)
+	if err != nil {
+		return prometheusHosts, err
+	}
+	sortGroups(groups)
+	for _, group := range groups {
+		groupVariables, err := getGroupVariables(config, group)
+		if err != nil {
+			return prometheusHosts, err
+		}
+		if prometheusConfig, ok := groupVariables[config.prometheus.configName]; ok {
+			hosts, err := getAllGroupHosts(config, group.Related.Hosts, nil)
+			if err != nil {
+				return prometheusHosts, err
+			}
+			for _, host := range hosts {
+				hostVariables, err := getHostVariables(config, host)
+				if err != nil {
+					return prometheusHosts, err
 				}
+				prometheusHosts = createPrometheusHosts(config, group.Name, hostVariables, prometheusConfig, prometheusHosts)
+			}
+		}
+	}
+	return prometheusHosts, nil
+}
+
+/// getHostWithBlackBoxConfig Returns the hosts with blackbox configuration
+func getHostWithBlackBoxConfig(config Config) ([]Host, error) {
+	return getAllHosts(config, "host_filter=variables__icontains="+url.QueryEscape(config.blackbox.configName), nil)
+}
+
+/// BlackboxGroupConfig is the blackbox config of a group, which is inherited by the hosts of the group
+type BlackboxGroupConfig struct {
+	Group   string
+	Config  interface{}
+	Probers interface{}
+}
+
+/// createBlackboxHost Creates the blackbox hosts of a single config entry for each of its probers, the targets
+/// default to the IpVar of the host. The target objects get their own blackbox hosts. Returns false when the
+/// entry is invalid.
+func createBlackboxHost(
+	config Config,
+	group string,
+	hostVariables map[string]interface{},
+	singleBlackboxConfig map[string]interface{},
+	groupProbers interface{}) ([]BlackboxHost, bool) {
+	labels := BlackboxHostLabel{}
+	if ipVar, ok := hostVariables[config.blackbox.IpVar]; ok {
+		labels.IP = fmt.Sprintf("%v", ipVar)
+	}
+	if hostNameVar, ok := hostVariables[config.blackbox.HostNameVar]; ok {
+		labels.Host = fmt.Sprintf("%v", hostNameVar)
+	}
+	if module, ok := singleBlackboxConfig["module"]; ok {
+		labels.Module = fmt.Sprintf("%v", module)
+	}
+	labels.Job = getBlackboxJobName(config)
+	labels.Group = group
+	// The probes pass the other parameters of the entry like the auth of the snmp exporter as labels
+	if len(config.blackbox.labels) > 0 || len(config.blackbox.params) > 0 {
+		labels.Extra = make(map[string]string)
+		for name, value := range config.blackbox.labels {
+			labels.Extra[name] = value
+		}
+		for _, param := range config.blackbox.params {
+			if value, ok := singleBlackboxConfig[param]; ok && param != "module" {
+				labels.Extra[param] = fmt.Sprintf("%v", value)
 			}
 		}
 	}
-	Next := allGroups.Next
-	if Next != "" {
-		parsedUrl, err := url.Parse(Next)
+	targetsConfig, hasTargets := singleBlackboxConfig["targets"]
+	if !hasTargets && labels.IP != "" {
+		targetsConfig = []interface{}{labels.IP}
+	}
+	targets, ok := targetsConfig.([]interface{})
+	if !ok {
+		recordValidationError(config.blackbox.configName, "the targets should be a list")
+		return nil, false
+	}
+	probers := getEntryProbers(config, singleBlackboxConfig, groupProbers)
+	blackboxHost := BlackboxHost{Labels: labels}
+	var targetHosts []BlackboxHost
+	for _, target := range targets {
+		if targetObject, ok := target.(map[string]interface{}); ok {
+			targetHost, ok := createTargetBlackboxHost(config, labels, hostVariables, targetObject)
+			if !ok {
+				continue
+			}
+			targetProbers := probers
+			if prober, ok := targetObject["prober"]; ok {
+				targetProbers = getProberNames(config, prober)
+			}
+			targetHosts = append(targetHosts, expandBlackboxProbers(config, targetHost, targetProbers)...)
+			continue
+		}
+		renderedTarget, err := renderTarget(fmt.Sprintf("%v", target), hostVariables)
+		if err == nil {
+			err = validateTarget(config, labels.Module, renderedTarget)
+		}
 		if err != nil {
-			log.Fatal("The given url can not be parsed", err)
+			recordValidationError(config.blackbox.configName, err.Error())
+			continue
 		}
-		nextPageQuery := parsedUrl.RawQuery
-		return createPrometheusConfig(config, nextPageQuery, prometheusHosts)
+		blackboxHost.Targets = append(blackboxHost.Targets, renderedTarget)
 	}
-	return prometheusHosts
+	var blackboxHosts []BlackboxHost
+	// An entry with an empty target list is kept, the entries whose targets are all invalid are dropped
+	if len(blackboxHost.Targets) > 0 || len(targets) == 0 {
+		blackboxHosts = expandBlackboxProbers(config, blackboxHost, probers)
+	}
+	return append(blackboxHosts, targetHosts...), true
 }
 
-/// getHostWithBlackBoxConfig Returns the hosts with blackbox configuration
-func getHostWithBlackBoxConfig(config Config) HostResults {
-	hosts := getHosts(config, "host_filter=variables__icontains=blackbox_config")
-	return hosts
+/// templateRegexp matches the {{ variable }} placeholders of the targets
+var templateRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+/// lookupVariable Returns the value of the given variable, the keys of nested maps are separated by dots
+func lookupVariable(variables map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = variables
+	for _, key := range strings.Split(path, ".") {
+		mapValue, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = mapValue[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+/// renderTarget Replaces the {{ variable }} placeholders of the target with the variables of the host
+func renderTarget(target string, variables map[string]interface{}) (string, error) {
+	var err error
+	rendered := templateRegexp.ReplaceAllStringFunc(target, func(placeholder string) string {
+		name := templateRegexp.FindStringSubmatch(placeholder)[1]
+		value, ok := lookupVariable(variables, name)
+		if !ok {
+			err = fmt.Errorf("the variable %s of the target %s is not defined", name, target)
+			return placeholder
+		}
+		return fmt.Sprintf("%v", value)
+	})
+	return rendered, err
+}
+
+/// getTemplateVariables Returns the variables the targets of the host are rendered with, which are the host
+/// variables, the inventory_hostname and the ansible_facts when they are loaded
+func getTemplateVariables(host Host, hostVariables map[string]interface{}, facts map[string]interface{}) map[string]interface{} {
+	variables := map[string]interface{}{"inventory_hostname": host.Name}
+	if facts != nil {
+		variables["ansible_facts"] = facts
+	}
+	for key, value := range hostVariables {
+		variables[key] = value
+	}
+	return variables
 }
 
 /// createBlackBoxHosts Creates the blackbox list from the host variables.
@@ -287,32 +561,120 @@ func createBlackBoxHosts(
 	hostVariables map[string]interface{},
 	blackboxHosts []BlackboxHost) []BlackboxHost {
 	if blackboxConfig, ok := hostVariables[config.blackbox.configName]; ok {
-		for _, singleBlackboxConfig := range blackboxConfig.([]interface{}) {
-			blackboxHost := BlackboxHost{}
-			labels := BlackboxHostLabel{}
-			if ipVar, ok := hostVariables[config.blackbox.IpVar]; ok {
-				labels.IP = fmt.Sprintf("%v", ipVar)
+		for _, singleBlackboxConfig := range getConfigEntries(config.blackbox.configName, blackboxConfig) {
+			if entryHosts, ok := createBlackboxHost(config, group, hostVariables, singleBlackboxConfig, nil); ok {
+				blackboxHosts = append(blackboxHosts, entryHosts...)
 			}
-			if hostNameVar, ok := hostVariables[config.blackbox.HostNameVar]; ok {
-				labels.Host = fmt.Sprintf("%v", hostNameVar)
+		}
+	}
+	return blackboxHosts
+}
+
+/// mergeBlackboxEntries Returns the group entry with the keys of the host entry set on top
+func mergeBlackboxEntries(groupEntry map[string]interface{}, hostEntry map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	for key, value := range groupEntry {
+		merged[key] = value
+	}
+	for key, value := range hostEntry {
+		merged[key] = value
+	}
+	return merged
+}
+
+/// createHostBlackboxHosts Creates the blackbox list of a host from the configs of its groups and its own config.
+/// The host entries are merged on top of the group entries with the same module, the other host entries are
+/// created for the groups selected by the group strategy.
+func createHostBlackboxHosts(
+	config Config,
+	host Host,
+	hostGroups []string,
+	hostVariables map[string]interface{},
+	groupConfigs []BlackboxGroupConfig,
+	blackboxHosts []BlackboxHost) []BlackboxHost {
+	var hostEntries []map[string]interface{}
+	if hostConfig, ok := hostVariables[config.blackbox.configName]; ok {
+		hostEntries = getConfigEntries(config.blackbox.configName, hostConfig)
+	}
+	merged := make([]bool, len(hostEntries))
+	for _, groupConfig := range groupConfigs {
+		if groupConfig.Config == nil {
+			continue
+		}
+		for _, entry := range getConfigEntries(config.blackbox.configName, groupConfig.Config) {
+			for i, hostEntry := range hostEntries {
+				if fmt.Sprintf("%v", hostEntry["module"]) == fmt.Sprintf("%v", entry["module"]) {
+					entry = mergeBlackboxEntries(entry, hostEntry)
+					merged[i] = true
+				}
 			}
-			if module, ok := singleBlackboxConfig.(map[string]interface{})["module"]; ok {
-				labels.Module = fmt.Sprintf("%v", module)
+			if entryHosts, ok := createBlackboxHost(config, groupConfig.Group, hostVariables, entry, groupConfig.Probers); ok {
+				blackboxHosts = append(blackboxHosts, entryHosts...)
 			}
-			labels.Job = "blackbox"
-			labels.Group = group
-			targets := singleBlackboxConfig.(map[string]interface{})["targets"].([]interface{})
-			for _, target := range targets {
-				blackboxHost.Targets = append(blackboxHost.Targets, fmt.Sprintf("%v", target))
+		}
+	}
+	var unmerged []map[string]interface{}
+	for i, hostEntry := range hostEntries {
+		if merged[i] == false {
+			unmerged = append(unmerged, hostEntry)
+		}
+	}
+	if tlsEntry, ok := getTLSEntry(config, hostVariables); ok {
+		unmerged = append(unmerged, tlsEntry)
+	}
+	if len(unmerged) == 0 {
+		return blackboxHosts
+	}
+	groups := selectBlackboxGroups(config, hostGroups)
+	if len(groups) == 0 {
+		recordValidationError(config.blackbox.configName, fmt.Sprintf("the host %s has no group that is not ignored and no FallbackGroup is set", host.Name))
+		return blackboxHosts
+	}
+	for _, group := range groups {
+		for _, hostEntry := range unmerged {
+			if entryHosts, ok := createBlackboxHost(config, group, hostVariables, hostEntry, getGroupProbers(groupConfigs, group)); ok {
+				blackboxHosts = append(blackboxHosts, entryHosts...)
 			}
-			blackboxHost.Labels = labels
-			blackboxHosts = append(blackboxHosts, blackboxHost)
 		}
-
 	}
 	return blackboxHosts
 }
 
+/// recordValidationError Logs and counts an invalid entry of the given AWX variable, the entries collected by
+/// collectValidationErrors are passed to the collector instead
+func recordValidationError(variableName string, reason string) {
+	validationErrorListener.Lock()
+	defer validationErrorListener.Unlock()
+	// The collected errors are reported by the caller like lint and explain, the coverage report creates the
+	// targets again and should not count them twice
+	if validationErrorListener.listener != nil {
+		validationErrorListener.listener(variableName, reason)
+		return
+	}
+	validationErrorsTotal.WithLabelValues(variableName).Inc()
+	log.Printf("Skipping an invalid entry of %s: %s", variableName, reason)
+}
+
+/// getConfigEntries Returns the entries of the given config variable, which should be a list of maps.
+/// The invalid entries are skipped.
+func getConfigEntries(variableName string, configValue interface{}) []map[string]interface{} {
+	var entries []map[string]interface{}
+	list, ok := configValue.([]interface{})
+	if !ok {
+		recordValidationError(variableName, "the variable should be a list")
+		return entries
+	}
+	for _, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			recordValidationError(variableName, "the entries should be maps")
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
 /// inSlice Checks if the given key exists in the given slice
 func inSlice(key string, dataList []string) bool {
 	for _, element := range dataList {
@@ -323,45 +685,92 @@ func inSlice(key string, dataList []string) bool {
 	return false
 }
 
-/// getBlackboxHostGroup Returns the right Group for the given blackbox
-/// It is the first group that is not inside the IgnoredGroups
-func getBlackboxHostGroup(config Config, host Host) string {
-	groups := host.SummaryFields.Groups.Results
-	for _, group := range groups {
-		if inSlice(group.Name, config.blackbox.IgnoredGroups) == false {
-			return group.Name
+/// createBlackboxConfig Creates the blackbox configuration objects that can be printed as json, from the
+/// groups and the hosts with blackbox configuration
+func createBlackboxConfig(config Config) ([]BlackboxHost, error) {
+	blackboxHosts := []BlackboxHost{}
+	hosts := make(map[int]Host)
+	groupConfigs := make(map[int][]BlackboxGroupConfig)
+	groups, err := getAllGroups(config, "variables__icontains="+url.QueryEscape(config.blackbox.configName), nil)
+	if err != nil {
+		return blackboxHosts, err
+	}
+	if config.blackbox.probersConfigName != "" {
+		groups, err = getAllGroups(config, "variables__icontains="+url.QueryEscape(config.blackbox.probersConfigName), groups)
+		if err != nil {
+			return blackboxHosts, err
 		}
 	}
-	return ""
-}
-
-/// createBlackboxConfig Creates the blackbox configuration objects that can be printed as json
-func createBlackboxConfig(config Config, nextPage string, blackboxHosts []BlackboxHost) []BlackboxHost {
-	hosts := HostResults{}
-	if nextPage == "" {
-		hosts = getHostWithBlackBoxConfig(config)
-	} else {
-		hosts = getHosts(config, nextPage)
-	}
-	if hosts.Count > 0 {
-		for _, host := range hosts.Results {
-			variables := getHostVariables(config, host)
-			group := getBlackboxHostGroup(config, host)
-			if group != "" {
-				blackboxHosts = createBlackBoxHosts(config, group, variables, blackboxHosts)
+	sortGroups(groups)
+	seenGroups := make(map[int]bool)
+	for _, group := range groups {
+		if seenGroups[group.ID] {
+			continue
+		}
+		seenGroups[group.ID] = true
+		groupVariables, err := getGroupVariables(config, group)
+		if err != nil {
+			return blackboxHosts, err
+		}
+		groupConfig := BlackboxGroupConfig{
+			Group:   group.Name,
+			Config:  groupVariables[config.blackbox.configName],
+			Probers: groupVariables[config.blackbox.probersConfigName],
+		}
+		if groupConfig.Config == nil && groupConfig.Probers == nil {
+			continue
+		}
+		groupHosts, err := getAllGroupHosts(config, group.Related.Hosts, nil)
+		if err != nil {
+			return blackboxHosts, err
+		}
+		for _, host := range groupHosts {
+			// A group with only default probers does not add its hosts
+			if groupConfig.Config != nil {
+				hosts[host.ID] = host
 			}
+			groupConfigs[host.ID] = append(groupConfigs[host.ID], groupConfig)
 		}
 	}
-	Next := hosts.Next
-	if Next != "" {
-		parsedUrl, err := url.Parse(Next)
+	configHosts, err := getHostWithBlackBoxConfig(config)
+	if err != nil {
+		return blackboxHosts, err
+	}
+	for _, host := range configHosts {
+		hosts[host.ID] = host
+	}
+	tlsHosts, err := getHostsWithTLSVariables(config)
+	if err != nil {
+		return blackboxHosts, err
+	}
+	for _, host := range tlsHosts {
+		hosts[host.ID] = host
+	}
+	var ids []int
+	for id := range hosts {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		variables, err := getHostVariables(config, hosts[id])
+		if err != nil {
+			return blackboxHosts, err
+		}
+		var facts map[string]interface{}
+		if config.blackbox.loadFacts {
+			facts, err = getHostFacts(config, hosts[id])
+			if err != nil {
+				return blackboxHosts, err
+			}
+		}
+		variables = getTemplateVariables(hosts[id], variables, facts)
+		groupNames, err := getHostGroupNames(config, hosts[id])
 		if err != nil {
-			log.Fatal("The given url can not be parsed", err)
+			return blackboxHosts, err
 		}
-		nextPageQuery := parsedUrl.RawQuery
-		return createBlackboxConfig(config, nextPageQuery, blackboxHosts)
+		blackboxHosts = createHostBlackboxHosts(config, hosts[id], groupNames, variables, groupConfigs[id], blackboxHosts)
 	}
-	return blackboxHosts
+	return blackboxHosts, nil
 }
 
 /// notifierExists checks if the given notifier exists in the given list, returns it when not gives error
@@ -503,13 +912,16 @@ func addNewReceivers(dataConfig *altMgrConfig.Config, notifiers []AlertManagerEm
 }
 
 /// createAlertManagerNotifiers Creates the Alert Manager configurations from an existing config file.
-func createAlertManagerConfig(config Config) *altMgrConfig.Config {
-	var notifiers []AlertManagerEmailNotifier
-	notifiers = getAlertManagerNotifiers(config, "", notifiers)
+func createAlertManagerConfig(config Config, source InventorySource) (*altMgrConfig.Config, error) {
+	notifiers, err := source.AlertManagerNotifiers(config)
+	if err != nil {
+		return nil, err
+	}
 	dataConfig, _, err := readAlertManagerConfig(config)
 	if err != nil {
-		log.Fatal("Can not read the alertmanager config", err)
+		return nil, fmt.Errorf("can not read the alertmanager config: %w", err)
 	}
+	sortAlertManagerNotifiers(notifiers)
 	// Remove the non existing receivers
 	removeNotExistingReceivers(dataConfig, notifiers)
 	// Update the existing receivers
@@ -522,16 +934,12 @@ func createAlertManagerConfig(config Config) *altMgrConfig.Config {
 	updateExistingRoutes(dataConfig, notifiers)
 	/// Add new Routes
 	addNewRoutes(dataConfig, notifiers)
-	return dataConfig
+	return dataConfig, nil
 }
 
 /// readAlertManagerConfig reads the alertmanager configurations
 func readAlertManagerConfig(applicationConfig Config) (*altMgrConfig.Config, []byte, error) {
-	dataConfig, content, err := altMgrConfig.LoadFile(applicationConfig.alertmanager.sourceFile)
-	if err != nil {
-		panic(err)
-	}
-	return dataConfig, content, err
+	return altMgrConfig.LoadFile(applicationConfig.alertmanager.sourceFile)
 }
 
 /// createAlertManagerNotifiers  Creates AlertManager notifiers the given configuration of the AWX
@@ -540,28 +948,28 @@ func createAlertManagerNotifiers(
 	group string,
 	alertManagerConfig interface{},
 	notifiers []AlertManagerEmailNotifier) []AlertManagerEmailNotifier {
-	for _, alertManagerSingleConfig := range alertManagerConfig.([]interface{}) {
+	for _, alertManagerSingleConfig := range getConfigEntries(config.alertmanager.configName, alertManagerConfig) {
 		// Find the notifier type and create
-		if notifierType, ok := alertManagerSingleConfig.(map[string]interface{})["type"]; ok {
+		if notifierType, ok := alertManagerSingleConfig["type"]; ok {
 			if notifierType == "email" {
 				emailNotifier := AlertManagerEmailNotifier{}
 				// Set the name of the given notifier
-				if name, ok := alertManagerSingleConfig.(map[string]interface{})["name"]; ok {
+				if name, ok := alertManagerSingleConfig["name"]; ok {
 					emailNotifier.Name = fmt.Sprintf("%v", name)
 				}
 				// Set the group of the given notifier
 				emailNotifier.Group = group
-				if receiverConfig, ok := alertManagerSingleConfig.(map[string]interface{})["receiver-config"]; ok {
-					if emailTo, ok := receiverConfig.(map[string]interface{})["to"]; ok {
+				if receiverConfig, ok := alertManagerSingleConfig["receiver-config"].(map[string]interface{}); ok {
+					if emailTo, ok := receiverConfig["to"]; ok {
 						emailNotifier.Email = fmt.Sprintf("%v", emailTo)
 					}
-					if requireTLS, ok := alertManagerSingleConfig.(map[string]interface{})["require-tls"]; ok {
-						emailNotifier.RequireTLS = requireTLS.(bool)
+					if requireTLS, ok := alertManagerSingleConfig["require-tls"].(bool); ok {
+						emailNotifier.RequireTLS = requireTLS
 					} else {
 						emailNotifier.RequireTLS = config.alertmanager.requireTls
 					}
-					if sendResolve, ok := alertManagerSingleConfig.(map[string]interface{})["send-resolve"]; ok {
-						emailNotifier.SendResolved = sendResolve.(bool)
+					if sendResolve, ok := alertManagerSingleConfig["send-resolve"].(bool); ok {
+						emailNotifier.SendResolved = sendResolve
 					} else {
 						emailNotifier.SendResolved = config.alertmanager.sendResolve
 					}
@@ -577,16 +985,23 @@ func createAlertManagerNotifiers(
 func getAlertManagerNotifiers(
 	config Config,
 	nextPage string,
-	alertManagerNotifiers []AlertManagerEmailNotifier) []AlertManagerEmailNotifier {
+	alertManagerNotifiers []AlertManagerEmailNotifier) ([]AlertManagerEmailNotifier, error) {
 	var groups GroupResults
+	var err error
 	if nextPage == "" {
-		groups = getGroups(config, "variables__icontains=alertmanager_config")
+		groups, err = getGroups(config, "variables__icontains=alertmanager_config")
 	} else {
-		groups = getGroups(config, nextPage)
+		groups, err = getGroups(config, nextPage)
+	}
+	if err != nil {
+		return alertManagerNotifiers, err
 	}
 	if groups.Count > 0 {
 		for _, group := range groups.Results {
-			groupVariables := getGroupVariables(config, group)
+			groupVariables, err := getGroupVariables(config, group)
+			if err != nil {
+				return alertManagerNotifiers, err
+			}
 			if alertManagerConfig, ok := groupVariables[config.alertmanager.configName]; ok {
 				alertManagerNotifiers = createAlertManagerNotifiers(
 					config,
@@ -596,44 +1011,158 @@ func getAlertManagerNotifiers(
 			}
 		}
 	}
-	Next := groups.Next
-	if Next != "" {
-		parsedUrl, err := url.Parse(Next)
-		if err != nil {
-			log.Fatal("The given url can not be parsed", err)
-		}
-		nextPageQuery := parsedUrl.RawQuery
+	nextPageQuery, err := getNextPageQuery(groups.Next)
+	if err != nil {
+		return alertManagerNotifiers, err
+	}
+	if nextPageQuery != "" {
 		return getAlertManagerNotifiers(config, nextPageQuery, alertManagerNotifiers)
 	}
-	return alertManagerNotifiers
+	return alertManagerNotifiers, nil
 }
 
-/// readConfiguration Returns the configurations file for the given path.
+/// readConfiguration Returns the configurations file for the given path, exits on errors.
 func readConfiguration(configPath string) Config {
-	cfg, err := ini.Load(configPath)
+	config, err := loadConfiguration(configPath, nil)
 	if err != nil {
-		fmt.Printf("Fail to read file: %v", err)
+		fmt.Printf("%v", err)
 		os.Exit(1)
 	}
+	return config
+}
+
+/// envPrefix is the prefix of the environment variables that override the configuration
+const envPrefix = "AWX_EXPORTER_"
+
+/// ConfigOverrides are the configuration keys set on the command line in the SECTION.Key=value format
+type ConfigOverrides []string
+
+/// String Returns the overrides separated by commas
+func (overrides *ConfigOverrides) String() string {
+	return strings.Join(*overrides, ",")
+}
+
+/// Set Adds the given override, it is used by the flag package
+func (overrides *ConfigOverrides) Set(value string) error {
+	_, _, _, err := parseConfigOverride(value)
+	if err != nil {
+		return err
+	}
+	*overrides = append(*overrides, value)
+	return nil
+}
+
+/// parseConfigOverride Returns the section, the key and the value of the given SECTION.Key=value override
+func parseConfigOverride(override string) (string, string, string, error) {
+	parts := strings.SplitN(override, "=", 2)
+	dot := strings.LastIndex(parts[0], ".")
+	if len(parts) != 2 || dot <= 0 || dot == len(parts[0])-1 {
+		return "", "", "", fmt.Errorf("the override %q should have the format SECTION.Key=value", override)
+	}
+	return parts[0][:dot], parts[0][dot+1:], parts[1], nil
+}
+
+/// applyConfigOverrides Sets the keys of the AWX_EXPORTER_<SECTION>_<KEY> environment variables and then
+/// the keys of the given overrides, so the command line wins.
+func applyConfigOverrides(cfg *ini.File, overrides ConfigOverrides) error {
+	for _, env := range os.Environ() {
+		parts := strings.SplitN(env, "=", 2)
+		if strings.HasPrefix(parts[0], envPrefix) == false || parts[0] == envPrefix+"CONFIG_PATH" {
+			continue
+		}
+		name := strings.SplitN(strings.TrimPrefix(parts[0], envPrefix), "_", 2)
+		if len(name) != 2 || name[0] == "" || name[1] == "" {
+			return fmt.Errorf("the environment variable %s should have the format %s<SECTION>_<KEY>", parts[0], envPrefix)
+		}
+		cfg.Section(name[0]).Key(name[1]).SetValue(parts[1])
+	}
+	for _, override := range overrides {
+		section, key, value, err := parseConfigOverride(override)
+		if err != nil {
+			return err
+		}
+		cfg.Section(section).Key(key).SetValue(value)
+	}
+	return nil
+}
+
+/// ConfigNames maps the lower case names of the insensitive load options to the user defined names of the
+/// configuration, which are the prober names of the [PROBERS] section and the [PROBE.<name>] sections
+type ConfigNames map[string]string
+
+/// loadConfigNames Returns the user defined names of the configuration file for the given path with the
+/// environment and the given overrides applied. The first spelling of a name is kept.
+func loadConfigNames(configPath string, overrides ConfigOverrides) (ConfigNames, error) {
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("Fail to read file: %v", err)
+	}
+	err = applyConfigOverrides(cfg, overrides)
+	if err != nil {
+		return nil, err
+	}
+	names := make(ConfigNames)
+	add := func(name string) {
+		if _, ok := names[strings.ToLower(name)]; !ok {
+			names[strings.ToLower(name)] = name
+		}
+	}
+	for _, section := range cfg.Sections() {
+		if strings.EqualFold(section.Name(), "PROBERS") {
+			for _, key := range section.Keys() {
+				add("PROBERS." + key.Name())
+			}
+		}
+		if strings.HasPrefix(strings.ToLower(section.Name()), "probe.") {
+			add(section.Name())
+		}
+	}
+	return names, nil
+}
+
+/// get Returns the user defined name of the given lower case name or the name itself when it is unknown
+func (names ConfigNames) get(name string) string {
+	if userName, ok := names[strings.ToLower(name)]; ok {
+		return userName
+	}
+	return name
+}
+
+/// loadConfiguration Returns the configurations file for the given path with the environment and the given
+/// overrides applied. The section and key names are case insensitive, but the prober and probe names keep
+/// their case for the labels and the jobs.
+func loadConfiguration(configPath string, overrides ConfigOverrides) (Config, error) {
+	cfg, err := ini.LoadSources(ini.LoadOptions{Insensitive: true}, configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("Fail to read file: %v", err)
+	}
+	err = applyConfigOverrides(cfg, overrides)
+	if err != nil {
+		return Config{}, err
+	}
+	names, err := loadConfigNames(configPath, overrides)
+	if err != nil {
+		return Config{}, err
+	}
+	probers := make(map[string]string)
+	for name, address := range cfg.Section("PROBERS").KeysHash() {
+		probers[names.get("PROBERS." + name)[len("PROBERS."):]] = address
+	}
 	configHostOverride, err := cfg.Section("PROMETHEUS").Key("ConfigHostOverride").Bool()
 	if err != nil {
-		fmt.Printf("The Host override in promtheus should be boolean: %v", err)
-		os.Exit(1)
+		return Config{}, fmt.Errorf("The Host override in promtheus should be boolean: %v", err)
 	}
 	timeout, err := cfg.Section("AWX").Key("TimeOut").Duration()
 	if err != nil {
-		fmt.Printf("The timeout in AWX should be an integer with unit (s,m,h,...): %v", err)
-		os.Exit(1)
+		return Config{}, fmt.Errorf("The timeout in AWX should be an integer with unit (s,m,h,...): %v", err)
 	}
 	alertManagerRequireTls, err := cfg.Section("ALERTMANAGER").Key("RequireTLSDefault").Bool()
 	if err != nil {
-		fmt.Printf("The RequireTLSDefault for the Alertmanager should be boolean: %v", err)
-		os.Exit(1)
+		return Config{}, fmt.Errorf("The RequireTLSDefault for the Alertmanager should be boolean: %v", err)
 	}
 	alertManagerSendResolve, err := cfg.Section("ALERTMANAGER").Key("SendResolveDefault").Bool()
 	if err != nil {
-		fmt.Printf("The SendResolveDefault for the Alertmanager should be boolean: %v", err)
-		os.Exit(1)
+		return Config{}, fmt.Errorf("The SendResolveDefault for the Alertmanager should be boolean: %v", err)
 	}
 	var config = Config{
 		awx: AWXConfig{
@@ -642,56 +1171,99 @@ func readConfiguration(configPath string) Config {
 			Token:            cfg.Section("AWX").Key("Token").String(),
 			Timeout:          timeout,
 			InventorySources: strings.Split(cfg.Section("AWX").Key("InventorySources").String(), ","),
+			Retries:          cfg.Section("AWX").Key("Retries").MustInt(2),
+			RetryDelay:       cfg.Section("AWX").Key("RetryDelay").MustDuration(time.Second),
 		},
 		prometheus: PrometheusConfig{
-			configName:         cfg.Section("PROMETHEUS").Key("ConfigName").String(),
-			configHostOverride: configHostOverride,
-			IpVar:              cfg.Section("PROMETHEUS").Key("IpVar").String(),
-			HostNameVar:        cfg.Section("PROMETHEUS").Key("HostNameVar").String(),
+			configName:             cfg.Section("PROMETHEUS").Key("ConfigName").String(),
+			configHostOverride:     configHostOverride,
+			IpVar:                  cfg.Section("PROMETHEUS").Key("IpVar").String(),
+			HostNameVar:            cfg.Section("PROMETHEUS").Key("HostNameVar").String(),
+			scrapeTargetMode:       cfg.Section("PROMETHEUS").Key("ScrapeTargetMode").In("static", []string{"static", "file_sd"}),
+			fileSDPath:             cfg.Section("PROMETHEUS").Key("FileSDPath").String(),
+			sourceFile:             cfg.Section("PROMETHEUS").Key("SourceFile").String(),
+			jobPrefix:              cfg.Section("PROMETHEUS").Key("JobPrefix").MustString("dynamic-"),
+			outputFile:             cfg.Section("PROMETHEUS").Key("OutputFile").String(),
+			scrapeConfigOutputFile: cfg.Section("PROMETHEUS").Key("ScrapeConfigOutputFile").String(),
+			configOutputFile:       cfg.Section("PROMETHEUS").Key("ConfigOutputFile").String(),
 		},
 		blackbox: BlackboxConfig{
-			configName:    cfg.Section("BLACKBOX").Key("ConfigName").String(),
-			IgnoredGroups: strings.Split(cfg.Section("BLACKBOX").Key("IgnoredGroups").String(), ","),
-			IpVar:         cfg.Section("BLACKBOX").Key("IpVar").String(),
-			HostNameVar:   cfg.Section("BLACKBOX").Key("HostNameVar").String(),
+			configName:             cfg.Section("BLACKBOX").Key("ConfigName").String(),
+			IgnoredGroups:          strings.Split(cfg.Section("BLACKBOX").Key("IgnoredGroups").String(), ","),
+			IpVar:                  cfg.Section("BLACKBOX").Key("IpVar").String(),
+			HostNameVar:            cfg.Section("BLACKBOX").Key("HostNameVar").String(),
+			exporterAddress:        cfg.Section("BLACKBOX").Key("ExporterAddress").String(),
+			fileSDPath:             cfg.Section("BLACKBOX").Key("FileSDPath").String(),
+			outputFile:             cfg.Section("BLACKBOX").Key("OutputFile").String(),
+			loadFacts:              cfg.Section("BLACKBOX").Key("LoadFacts").MustBool(false),
+			groupStrategy:          cfg.Section("BLACKBOX").Key("GroupStrategy").In(groupStrategyFirst, []string{groupStrategyFirst, groupStrategyPriority, groupStrategyAll}),
+			preferredGroups:        cfg.Section("BLACKBOX").Key("PreferredGroups").Strings(","),
+			fallbackGroup:          cfg.Section("BLACKBOX").Key("FallbackGroup").String(),
+			modulesConfigName:      cfg.Section("BLACKBOX").Key("ModulesConfigName").MustString("blackbox_modules"),
+			modulesSourceFile:      cfg.Section("BLACKBOX").Key("ModulesSourceFile").String(),
+			modulesOutputFile:      cfg.Section("BLACKBOX").Key("ModulesOutputFile").String(),
+			jobName:                cfg.Section("BLACKBOX").Key("JobName").MustString("blackbox"),
+			scrapeJobs:             cfg.Section("BLACKBOX").Key("ScrapeJobs").In(blackboxScrapeJobsModule, []string{blackboxScrapeJobsModule, blackboxScrapeJobsCombined}),
+			scrapeConfigOutputFile: cfg.Section("BLACKBOX").Key("ScrapeConfigOutputFile").String(),
+			probersConfigName:      cfg.Section("BLACKBOX").Key("ProbersConfigName").MustString("blackbox_probers"),
+			defaultProbers:         cfg.Section("BLACKBOX").Key("DefaultProbers").Strings(","),
+			probers:                probers,
+			tlsVariables:           cfg.Section("BLACKBOX").Key("TLSVariables").Strings(","),
+			tlsModule:              cfg.Section("BLACKBOX").Key("TLSModule").MustString("tls_connect"),
+			tlsPort:                cfg.Section("BLACKBOX").Key("TLSPort").MustInt(443),
+			resolveTargets:         cfg.Section("BLACKBOX").Key("ResolveTargets").MustBool(false),
 		},
 		alertmanager: AlertManagerConfig{
 			configName:  cfg.Section("ALERTMANAGER").Key("ConfigName").String(),
 			sourceFile:  cfg.Section("ALERTMANAGER").Key("SourceFile").String(),
 			sendResolve: alertManagerSendResolve,
 			requireTls:  alertManagerRequireTls,
+			outputFile:  cfg.Section("ALERTMANAGER").Key("OutputFile").String(),
+		},
+		safetyGuard: SafetyGuardConfig{
+			maxDropPercent:  cfg.Section("SAFETYGUARD").Key("MaxDropPercent").MustFloat64(50),
+			maxDropAbsolute: cfg.Section("SAFETYGUARD").Key("MaxDropAbsolute").MustInt(0),
+		},
+		state: StateConfig{
+			directory: cfg.Section("STATE").Key("Directory").String(),
+		},
+		metrics: MetricsConfig{
+			inventoryMetrics: cfg.Section("METRICS").Key("InventoryMetrics").MustBool(false),
+		},
+		webhook: WebhookConfig{
+			secret:   cfg.Section("WEBHOOK").Key("Secret").String(),
+			debounce: cfg.Section("WEBHOOK").Key("Debounce").MustDuration(30 * time.Second),
+		},
+		sync: SyncConfig{
+			incremental:      cfg.Section("SYNC").Key("Incremental").MustBool(true),
+			fullSyncInterval: cfg.Section("SYNC").Key("FullSyncInterval").MustDuration(time.Hour),
+		},
+		server: ServerConfig{
+			listenAddress:   cfg.Section("SERVER").Key("ListenAddress").MustString(":9710"),
+			refreshInterval: cfg.Section("SERVER").Key("RefreshInterval").MustDuration(5 * time.Minute),
+			tlsCertFile:     cfg.Section("SERVER").Key("TLSCertFile").String(),
+			tlsKeyFile:      cfg.Section("SERVER").Key("TLSKeyFile").String(),
 		},
 	}
-	return config
+	config.blackbox.moduleProbers, err = loadModuleProbers(config.blackbox, cfg.Section("BLACKBOX").Key("ModuleProbers").Strings(","))
+	if err != nil {
+		return Config{}, err
+	}
+	config.probe, err = loadProbesConfig(cfg, config.blackbox, names)
+	if err != nil {
+		return Config{}, err
+	}
+	err = validateGroupPatterns("BLACKBOX.IgnoredGroups", config.blackbox.IgnoredGroups)
+	if err != nil {
+		return Config{}, err
+	}
+	err = validateGroupPatterns("BLACKBOX.PreferredGroups", config.blackbox.preferredGroups)
+	if err != nil {
+		return Config{}, err
+	}
+	return config, nil
 }
 
 func main() {
-	configPath := flag.String("config-path", "config.ini", "The path to the configuration")
-	alertManagerMode := flag.Bool("alertmanager", false, "The Alert Manager mode for the exporter")
-	prometheusMode := flag.Bool("prometheus", false, "The Prometheus mode for the exporter")
-	blackboxMode := flag.Bool("blackbox", false, "Blackbox mode for the exporter")
-	flag.Parse()
-	config := readConfiguration(*configPath)
-	if *alertManagerMode {
-		alertManagerConfig := createAlertManagerConfig(config)
-		fmt.Println(alertManagerConfig)
-	}
-	if *prometheusMode {
-		var prometheusHosts []PrometheusHost
-		prometheusHosts = createPrometheusConfig(config, "", prometheusHosts)
-		printable, err := json.Marshal(prometheusHosts)
-		if err != nil {
-			log.Fatal("Error marshaling prometheus host", err)
-		}
-		fmt.Println(string(printable))
-	}
-	if *blackboxMode {
-		var blackboxHosts []BlackboxHost
-		blackboxHosts = createBlackboxConfig(config, "", blackboxHosts)
-		printable, err := json.Marshal(blackboxHosts)
-		if err != nil {
-			log.Fatal("Error marshaling blackbox host", err)
-		}
-		fmt.Println(string(printable))
-	}
+	os.Exit(runCommand(os.Args[1:]))
 }
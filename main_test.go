@@ -1,13 +1,31 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v2"
 )
 
 /// TestReadConfiguration tests the configuration reading functions
@@ -64,7 +82,7 @@ func TestCreateAWXRequest(t *testing.T) {
 	config.awx.Token = awxToken
 	path := "inventories"
 	method := "GET"
-	req := createAuthenticateAWXRequest(config, path, method, nil, false)
+	req, _ := createAuthenticateAWXRequest(config, path, method, nil, false)
 	path = fmt.Sprintf("/api/v2/%s", path)
 	parsedUrl, _ := url.Parse(config.awx.Host)
 
@@ -89,14 +107,17 @@ func TestSendRequest(t *testing.T) {
 	config.awx.Token = awxToken
 	path := "inventories"
 	method := "GET"
-	req := createAuthenticateAWXRequest(config, path, method, nil, false)
-	response := sendRequest(req, config)
+	req, err := createAuthenticateAWXRequest(config, path, method, nil, false)
+	response, err := sendRequest(req, err, config)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if response.StatusCode != 200 {
 		t.Errorf("The response status was not 200")
 	}
 	decoder := json.NewDecoder(response.Body)
 	var results InventoryResult
-	err := decoder.Decode(&results)
+	err = decoder.Decode(&results)
 	if err != nil {
 		t.Errorf("There was an error decoding or retrving the data")
 	}
@@ -111,7 +132,7 @@ func testCreatePrometheusConfig(t *testing.T) {
 	config := readConfiguration("config_test.ini")
 	config.awx.Token = awxToken
 	var groups []PrometheusHost
-	groupsRes := createPrometheusConfig(config, "", groups)
+	groupsRes, _ := createPrometheusConfig(config, "", groups)
 	if len(groupsRes) == 0 {
 		t.Errorf("The results are not valid")
 	}
@@ -121,9 +142,8 @@ func testCreatePrometheusConfig(t *testing.T) {
 func testCreateBlackboxConfig(t *testing.T) {
 	awxToken := os.Getenv("AWX_TOKEN")
 	config := readConfiguration("config_test.ini")
-	var hosts []BlackboxHost
 	config.awx.Token = awxToken
-	blackboxHosts := createBlackboxConfig(config, "", hosts)
+	blackboxHosts, _ := createBlackboxConfig(config)
 	if len(blackboxHosts) == 0 {
 		t.Errorf("The results are not valid")
 	}
@@ -134,7 +154,7 @@ func TestGetAlertManagerNotifiers(t *testing.T) {
 	config := readConfiguration("config_test.ini")
 	var alertManagerNotifiers []AlertManagerEmailNotifier
 	config.awx.Token = awxToken
-	alertNotifiers := getAlertManagerNotifiers(config, "", alertManagerNotifiers)
+	alertNotifiers, _ := getAlertManagerNotifiers(config, "", alertManagerNotifiers)
 	if len(alertNotifiers) == 0 {
 		t.Errorf("The results are not valid")
 	}
@@ -154,9 +174,1512 @@ func TestCreateAlertManagerConfig(t *testing.T) {
 	awxToken := os.Getenv("AWX_TOKEN")
 	config := readConfiguration("config_test.ini")
 	config.awx.Token = awxToken
-	alertManagerConfig := createAlertManagerConfig(config)
+	alertManagerConfig, err := createAlertManagerConfig(config, awxSource{})
+	if err != nil {
+		t.Fatal(err)
+	}
 	if len(alertManagerConfig.Route.Routes) == 0 {
 		t.Errorf("The results are not valid")
 
 	}
 }
+
+/// TestCreatePrometheusScrapeConfigs Tests that one job is created for each prometheus_config name
+func TestCreatePrometheusScrapeConfigs(t *testing.T) {
+	config := Config{}
+	prometheusHosts := []PrometheusHost{
+		{
+			Labels:  PrometheusHostLabel{Group: "web", Host: "web1", IP: "10.0.0.1", Job: "node"},
+			Targets: []string{"10.0.0.1:9100"},
+			Scrape:  PrometheusScrapeSettings{Scheme: "https", ScrapeInterval: "30s"},
+		},
+		{
+			Labels:  PrometheusHostLabel{Group: "db", Host: "db1", IP: "10.0.0.2", Job: "mysql"},
+			Targets: []string{"10.0.0.2:9104"},
+		},
+		{
+			Labels:  PrometheusHostLabel{Group: "db", Host: "db1", IP: "10.0.0.2", Job: "node"},
+			Targets: []string{"10.0.0.2:9100"},
+			Scrape:  PrometheusScrapeSettings{Scheme: "https", ScrapeInterval: "30s"},
+		},
+	}
+	scrapeConfigs := createPrometheusScrapeConfigs(config, prometheusHosts)
+	if len(scrapeConfigs) != 2 {
+		t.Fatalf("Expected 2 jobs, got %d", len(scrapeConfigs))
+	}
+	if scrapeConfigs[0].JobName != "mysql" || scrapeConfigs[1].JobName != "node" {
+		t.Errorf("The jobs are not sorted by name")
+	}
+	if scrapeConfigs[1].Scheme != "https" || scrapeConfigs[1].ScrapeInterval != "30s" {
+		t.Errorf("The scrape settings of the job are not set")
+	}
+	if len(scrapeConfigs[1].StaticConfigs) != 2 {
+		t.Errorf("The node job should have 2 static configs")
+	}
+	config.prometheus.scrapeTargetMode = "file_sd"
+	config.prometheus.fileSDPath = "/etc/prometheus/awx.json"
+	scrapeConfigs = createPrometheusScrapeConfigs(config, prometheusHosts)
+	if len(scrapeConfigs[0].StaticConfigs) != 0 || len(scrapeConfigs[0].FileSDConfigs) != 1 {
+		t.Errorf("The file_sd mode should not create static configs")
+	}
+	if scrapeConfigs[0].RelabelConfigs[0].Regex != "mysql" {
+		t.Errorf("The file_sd targets are not filtered by the job")
+	}
+	scrapeConfig := ScrapeConfig{}
+	setTargetsSD(config, &scrapeConfig, config.prometheus.fileSDPath, "job", "node.exporter+", nil)
+	if scrapeConfig.RelabelConfigs[0].Regex != `node\.exporter\+` {
+		t.Errorf("The job name should be matched literally, got %s", scrapeConfig.RelabelConfigs[0].Regex)
+	}
+}
+
+/// TestCreateBlackboxScrapeConfigs Tests the blackbox jobs and their relabeling
+func TestCreateBlackboxScrapeConfigs(t *testing.T) {
+	config := Config{}
+	config.blackbox.exporterAddress = "blackbox:9115"
+	blackboxHosts := []BlackboxHost{
+		{
+			Labels:  BlackboxHostLabel{Group: "web", Host: "web1", Job: "blackbox", Module: "http_2xx"},
+			Targets: []string{"https://example.com/"},
+		},
+	}
+	scrapeConfigs := createBlackboxScrapeConfigs(config, blackboxHosts)
+	if len(scrapeConfigs) != 1 || scrapeConfigs[0].JobName != "blackbox_http_2xx" {
+		t.Fatalf("The blackbox job was not created")
+	}
+	if scrapeConfigs[0].Params["module"][0] != "http_2xx" {
+		t.Errorf("The module param is not set")
+	}
+	relabelConfigs := scrapeConfigs[0].RelabelConfigs
+	if relabelConfigs[len(relabelConfigs)-1].Replacement != "blackbox:9115" {
+		t.Errorf("The address is not replaced with the exporter address")
+	}
+}
+
+/// TestMergeScrapeConfigs Tests that only the prefixed jobs are managed in the prometheus config
+func TestMergeScrapeConfigs(t *testing.T) {
+	source := `
+global:
+  scrape_interval: 15s
+rule_files:
+  - /etc/prometheus/rules/*.yml
+scrape_configs:
+  - job_name: prometheus
+    static_configs:
+      - targets: ['localhost:9090']
+  - job_name: dynamic-node
+    static_configs:
+      - targets: ['old:9100']
+  - job_name: dynamic-removed
+    static_configs:
+      - targets: ['removed:9100']
+remote_write:
+  - url: http://remote/write
+`
+	var prometheusFileConfig yaml.MapSlice
+	err := yaml.Unmarshal([]byte(source), &prometheusFileConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrapeConfigs := []ScrapeConfig{
+		{JobName: "node", StaticConfigs: []StaticConfig{{Targets: []string{"new:9100"}}}},
+		{JobName: "mysql", StaticConfigs: []StaticConfig{{Targets: []string{"db:9104"}}}},
+	}
+	merged, err := mergeScrapeConfigs(PrometheusFileConfig(prometheusFileConfig), "dynamic-", scrapeConfigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jobNames []string
+	for _, item := range merged {
+		if item.Key == "scrape_configs" {
+			for _, job := range item.Value.([]interface{}) {
+				jobNames = append(jobNames, getJobName(job))
+			}
+		}
+	}
+	expected := []string{"prometheus", "dynamic-node", "dynamic-mysql"}
+	if reflect.DeepEqual(jobNames, expected) == false {
+		t.Errorf("The merged jobs are not valid: %v", jobNames)
+	}
+	if strings.Contains(merged.String(), "new:9100") == false {
+		t.Errorf("The existing dynamic job was not updated")
+	}
+	if strings.Contains(merged.String(), "http://remote/write") == false || merged[1].Key != "rule_files" {
+		t.Errorf("The not managed keys were not kept")
+	}
+}
+
+/// TestReloadConfiguration Tests that the reload of the watch mode selects the modes of the new configuration
+func TestReloadConfiguration(t *testing.T) {
+	content, err := os.ReadFile("config.ini.dist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(t.TempDir(), "config.ini")
+	err = os.WriteFile(configPath, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selectModes := func(config Config) []Mode {
+		var modes []Mode
+		for _, mode := range getModes() {
+			if mode.OutputFile(config) != "" {
+				modes = append(modes, mode)
+			}
+		}
+		return modes
+	}
+	_, modes, err := reloadConfiguration(configPath, nil, selectModes)
+	if err != nil || len(modes) != 0 {
+		t.Fatalf("The distributed config should not select modes, got %v: %v", getModeNames(modes), err)
+	}
+	content = bytes.Replace(content, []byte("[PROBE]\nOutputFile=''"), []byte("[PROBE]\nOutputFile='probe.json'"), 1)
+	err = os.WriteFile(configPath, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, modes, err = reloadConfiguration(configPath, nil, selectModes)
+	if err != nil || reflect.DeepEqual(getModeNames(modes), []string{"probe"}) == false {
+		t.Errorf("The reload should select the probe mode, got %v: %v", getModeNames(modes), err)
+	}
+	_, _, err = reloadConfiguration(configPath, nil, func(config Config) []Mode { return getModes() })
+	if err == nil {
+		t.Errorf("The modes without output file should not be reloaded")
+	}
+}
+
+/// TestRunServer Tests that the server finishes the running requests when it is stopped by a signal
+func TestRunServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan bool)
+	release := make(chan bool)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-release
+		_, _ = w.Write([]byte("ok"))
+	})}
+	signals := make(chan os.Signal, 1)
+	exitCode := make(chan int, 1)
+	go func() {
+		exitCode <- runServer(Config{}, server, listener, signals)
+	}()
+	responses := make(chan int, 1)
+	go func() {
+		response, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responses <- 0
+			return
+		}
+		response.Body.Close()
+		responses <- response.StatusCode
+	}()
+	<-started
+	signals <- syscall.SIGTERM
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	if code := <-responses; code != http.StatusOK {
+		t.Errorf("The running request should be finished, got %d", code)
+	}
+	if code := <-exitCode; code != 0 {
+		t.Errorf("The stopped server should exit with 0, got %d", code)
+	}
+}
+
+/// TestSDCacheHandler Tests the ETag handling and the stale results of the service discovery server
+func TestSDCacheHandler(t *testing.T) {
+	cache := newSDCache()
+	fail := false
+	cache.modes = map[string]Mode{
+		"prometheus": {
+			Name: "prometheus",
+			Generate: func(config Config, source InventorySource) ([]byte, error) {
+				if fail {
+					return nil, errors.New("awx is not available")
+				}
+				return []byte(`[{"labels":{"job":"node"},"targets":["10.0.0.1:9100"]}]`), nil
+			},
+		},
+	}
+	handler := cache.handler("prometheus")
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/sd/prometheus", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("The server should not be available before the first refresh")
+	}
+	cache.refresh(Config{})
+	fail = true
+	cache.refresh(Config{})
+	recorder = httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("GET", "/sd/prometheus", nil))
+	if recorder.Code != http.StatusOK || strings.Contains(recorder.Body.String(), "10.0.0.1:9100") == false {
+		t.Errorf("The stale targets should be served on errors")
+	}
+	request := httptest.NewRequest("GET", "/sd/prometheus", nil)
+	request.Header.Set("If-None-Match", recorder.Header().Get("ETag"))
+	recorder = httptest.NewRecorder()
+	handler(recorder, request)
+	if recorder.Code != http.StatusNotModified {
+		t.Errorf("The server should return not modified for the same ETag")
+	}
+}
+
+/// TestRegenerate Tests that the output files are kept when a mode can not be generated
+func TestRegenerate(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "targets.json")
+	content := []byte("[]")
+	var err error
+	mode := Mode{
+		Name:       "test",
+		Generate:   func(config Config, source InventorySource) ([]byte, error) { return content, err },
+		OutputFile: func(config Config) string { return outputFile },
+	}
+	regenerate(Config{}, []Mode{mode}, newInventorySyncer())
+	content = nil
+	err = errors.New("awx is not available")
+	regenerate(Config{}, []Mode{mode}, newInventorySyncer())
+	written, readErr := os.ReadFile(outputFile)
+	if readErr != nil || string(written) != "[]" {
+		t.Errorf("The output file should be kept when the generation fails")
+	}
+	mode.OutputFile = func(config Config) string { return "" }
+	if checkOutputFiles(Config{}, []Mode{mode}) == nil {
+		t.Errorf("The modes without output file should not be accepted")
+	}
+}
+
+/// TestWriteOutputFile Tests that the output file is only written when the content changed
+func TestWriteOutputFile(t *testing.T) {
+	outputFile := filepath.Join(t.TempDir(), "targets.json")
+	changed, err := writeOutputFile(outputFile, []byte("[]"))
+	if err != nil || changed == false {
+		t.Fatalf("The new file should be written: %v", err)
+	}
+	changed, err = writeOutputFile(outputFile, []byte("[]"))
+	if err != nil || changed {
+		t.Errorf("The unchanged file should not be written")
+	}
+	changed, err = writeOutputFile(outputFile, []byte("[{}]"))
+	if err != nil || changed == false {
+		t.Errorf("The changed file should be written")
+	}
+	files, _ := os.ReadDir(filepath.Dir(outputFile))
+	if len(files) != 1 {
+		t.Errorf("The temporary files should be removed")
+	}
+}
+
+/// TestSortPrometheusHosts Tests that the prometheus hosts are sorted by job, group and host
+func TestSortPrometheusHosts(t *testing.T) {
+	prometheusHosts := []PrometheusHost{
+		{Labels: PrometheusHostLabel{Job: "node", Group: "web", Host: "web2"}},
+		{Labels: PrometheusHostLabel{Job: "node", Group: "web", Host: "web1"}},
+		{Labels: PrometheusHostLabel{Job: "mysql", Group: "db", Host: "db1"}},
+	}
+	sortPrometheusHosts(prometheusHosts)
+	if prometheusHosts[0].Labels.Job != "mysql" || prometheusHosts[1].Labels.Host != "web1" {
+		t.Errorf("The prometheus hosts are not sorted")
+	}
+}
+
+/// TestCheckSafetyGuard Tests that large drops of the targets are blocked
+func TestCheckSafetyGuard(t *testing.T) {
+	config := Config{}
+	config.safetyGuard.maxDropPercent = 50
+	mode := Mode{Name: "prometheus", Summarize: summarizeTargets}
+	previous := []byte(`[
+		{"labels":{"group":"web","job":"node"},"targets":["10.0.0.1:9100","10.0.0.2:9100"]},
+		{"labels":{"group":"db","job":"node"},"targets":["10.0.0.3:9100"]}
+	]`)
+	current := []byte(`[{"labels":{"group":"web","job":"node"},"targets":["10.0.0.1:9100","10.0.0.2:9100"]}]`)
+	if err := checkSafetyGuard(config, mode, previous, current); err != nil {
+		t.Errorf("A drop below the percentage should not be blocked: %v", err)
+	}
+	err := checkSafetyGuard(config, mode, previous, []byte("null"))
+	if errors.Is(err, errSafetyGuard) == false || strings.Contains(err.Error(), "db,web") == false {
+		t.Errorf("The empty result should be blocked with the vanished groups: %v", err)
+	}
+	config.safetyGuard.maxDropAbsolute = 0
+	config.safetyGuard.maxDropPercent = 10
+	if err := checkSafetyGuard(config, mode, previous, current); errors.Is(err, errSafetyGuard) == false {
+		t.Errorf("A drop above the percentage should be blocked")
+	}
+	if err := checkSafetyGuard(config, mode, nil, current); err != nil {
+		t.Errorf("Without a previous output nothing should be blocked")
+	}
+}
+
+/// TestStateFallback Tests that the last known good result is served when AWX fails
+func TestStateFallback(t *testing.T) {
+	config := Config{}
+	config.state.directory = t.TempDir()
+	fail := false
+	modes := map[string]Mode{
+		"prometheus": {
+			Name: "prometheus",
+			Generate: func(config Config, source InventorySource) ([]byte, error) {
+				if fail {
+					return nil, errors.New("awx is not available")
+				}
+				return []byte(`[{"labels":{"job":"node"},"targets":["10.0.0.1:9100"]}]`), nil
+			},
+		},
+	}
+	cache := newSDCache()
+	cache.modes = modes
+	cache.refresh(config)
+	fail = true
+	restarted := newSDCache()
+	restarted.modes = modes
+	restarted.loadStates(config)
+	restarted.refresh(config)
+	entry, ok := restarted.get("prometheus")
+	if !ok || strings.Contains(string(entry.Content), "10.0.0.1:9100") == false || entry.LastError == nil {
+		t.Errorf("The last known good result should be served after a restart")
+	}
+	outputFile := filepath.Join(t.TempDir(), "targets.json")
+	mode := modes["prometheus"]
+	mode.OutputFile = func(config Config) string { return outputFile }
+	regenerate(config, []Mode{mode}, newInventorySyncer())
+	written, err := os.ReadFile(outputFile)
+	if err != nil || strings.Contains(string(written), "10.0.0.1:9100") == false {
+		t.Errorf("The last known good result should be written when AWX fails")
+	}
+	fail = false
+	config.safetyGuard.maxDropPercent = 50
+	mode.Summarize = summarizeTargets
+	mode.Generate = func(config Config, source InventorySource) ([]byte, error) { return []byte("[]"), nil }
+	regenerate(config, []Mode{mode}, newInventorySyncer())
+	state, _, err := loadState(config, "prometheus")
+	if err != nil || strings.Contains(string(state), "10.0.0.1:9100") == false {
+		t.Errorf("The content blocked by the safety guard should not be saved as state, got %s", state)
+	}
+}
+
+/// TestGetAWXResultsRetries Tests that the server errors of AWX are retried
+func TestGetAWXResultsRetries(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"count": 1, "results": [{"id": 1, "name": "web"}]}`))
+	}))
+	defer server.Close()
+	config := Config{}
+	config.awx.Host = server.URL
+	config.awx.Timeout = time.Second
+	config.awx.Retries = 1
+	results, err := getGroups(config, "")
+	if err != nil || results.Count != 1 || requests != 2 {
+		t.Errorf("The request should be retried once: %v", err)
+	}
+	config.awx.Retries = 0
+	requests = 0
+	_, err = getGroups(config, "")
+	if err == nil {
+		t.Errorf("The server error should be returned without retries")
+	}
+}
+
+/// TestGetEndpointLabel Tests that the ids and queries are removed from the endpoint label
+func TestGetEndpointLabel(t *testing.T) {
+	if getEndpointLabel("/api/v2/groups/12/variable_data/") != "groups/:id/variable_data" {
+		t.Errorf("The ids are not removed from the endpoint")
+	}
+	if getEndpointLabel("hosts/?host_filter=variables__icontains=blackbox_config") != "hosts" {
+		t.Errorf("The query is not removed from the endpoint")
+	}
+}
+
+/// TestCreatePrometheusHostsInvalidConfig Tests that invalid entries are skipped
+func TestCreatePrometheusHostsInvalidConfig(t *testing.T) {
+	config := Config{}
+	config.prometheus.configName = "prometheus_config"
+	config.prometheus.IpVar = "ansible_host"
+	prometheusConfig := []interface{}{
+		"invalid",
+		map[string]interface{}{"name": "node", "port": float64(9100)},
+	}
+	hostVariables := map[string]interface{}{"ansible_host": "10.0.0.1"}
+	prometheusHosts := createPrometheusHosts(config, "web", hostVariables, prometheusConfig, nil)
+	if len(prometheusHosts) != 1 || prometheusHosts[0].Targets[0] != "10.0.0.1:9100" {
+		t.Errorf("The valid entries should be created")
+	}
+	if len(createPrometheusHosts(config, "web", hostVariables, "invalid", nil)) != 0 {
+		t.Errorf("The invalid config should be skipped")
+	}
+}
+
+/// TestCreateInventoryMetrics Tests the metrics of the AWX inventory state
+func TestCreateInventoryMetrics(t *testing.T) {
+	inventories := []Inventory{{Name: "servers", TotalHosts: 2, InventorySourcesWithFailures: 1}}
+	group := Group{Name: "web", TotalHosts: 2, HostsWithActiveFailures: 1}
+	group.SummaryFields.Inventory.Name = "servers"
+	failedHost := Host{ID: 1, Name: "web1", Enabled: true, HasActiveFailures: true}
+	failedHost.SummaryFields.Inventory.Name = "servers"
+	failedHost.SummaryFields.LastJob = JobSummary{ID: 10, Status: "failed", JobTemplateName: "deploy", Finished: time.Unix(1700000000, 0)}
+	disabledHost := Host{ID: 2, Name: "web2", Enabled: false}
+	disabledHost.SummaryFields.Inventory.Name = "servers"
+	metrics := createInventoryMetrics(inventories, []Group{group, group}, []Host{failedHost, disabledHost})
+	// 4 inventory, 2 group, 2 + 2 host and 2 last job metrics
+	if len(metrics) != 12 {
+		t.Errorf("Expected 12 metrics, got %d", len(metrics))
+	}
+	collector := &InventoryCollector{metrics: metrics}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "awx_host_last_job_finished_timestamp_seconds" {
+			if family.GetMetric()[0].GetGauge().GetValue() != 1700000000 {
+				t.Errorf("The finish time of the last job is not valid")
+			}
+			return
+		}
+	}
+	t.Errorf("The last job metrics were not created")
+}
+
+/// TestCreateInventoryMetricsSameNames Tests the metrics of the inventories and hosts with the same names in
+/// different organizations
+func TestCreateInventoryMetricsSameNames(t *testing.T) {
+	inventories := []Inventory{{ID: 1, Name: "servers"}, {ID: 2, Name: "servers"}}
+	inventories[0].SummaryFields.Organization.Name = "it"
+	inventories[1].SummaryFields.Organization.Name = "research"
+	var hosts []Host
+	for id := 1; id <= 2; id++ {
+		host := Host{ID: id, Name: "web1"}
+		host.SummaryFields.Inventory = InventorySummary{ID: id, Name: "servers"}
+		hosts = append(hosts, host)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&InventoryCollector{metrics: createInventoryMetrics(inventories, nil, hosts)})
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "awx_host_enabled" && len(family.GetMetric()) != 2 {
+			t.Errorf("Expected both hosts named web1, got %d", len(family.GetMetric()))
+		}
+	}
+	model := newInventoryModel()
+	model.hosts[1] = &ModelHost{Host: hosts[0]}
+	model.groups[1] = &ModelGroup{Group: Group{ID: 1, Name: "web"}}
+	groups, modelHosts, err := getInventoryObjects(Config{}, model)
+	if err != nil || len(groups) != 1 || len(modelHosts) != 1 {
+		t.Errorf("Expected the groups and hosts of the model, got %v %v %v", groups, modelHosts, err)
+	}
+}
+
+/// TestValidateWebhookRequest Tests the shared secret and the HMAC signature of the AWX notifications
+func TestValidateWebhookRequest(t *testing.T) {
+	body := []byte(`{"id": 1, "status": "successful"}`)
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	if validateWebhookRequest("secret", header, body) == false {
+		t.Errorf("The shared secret should be accepted")
+	}
+	header.Set("Authorization", "Bearer wrong")
+	if validateWebhookRequest("secret", header, body) {
+		t.Errorf("The wrong secret should not be accepted")
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	header = http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	if validateWebhookRequest("secret", header, body) == false {
+		t.Errorf("The valid signature should be accepted")
+	}
+	if validateWebhookRequest("secret", header, []byte("changed")) {
+		t.Errorf("The signature of another body should not be accepted")
+	}
+}
+
+/// TestWebhookHandlerDebounce Tests that a burst of notifications triggers a single regeneration
+func TestWebhookHandlerDebounce(t *testing.T) {
+	generated := make(chan bool, 10)
+	cache := newSDCache()
+	cache.modes = map[string]Mode{
+		"prometheus": {
+			Name: "prometheus",
+			Generate: func(config Config, source InventorySource) ([]byte, error) {
+				generated <- true
+				return []byte("[]"), nil
+			},
+		},
+	}
+	config := Config{}
+	config.webhook.secret = "secret"
+	config.webhook.debounce = 50 * time.Millisecond
+	handler := newWebhookHandler(config, cache)
+	for i := 0; i < 3; i++ {
+		request := httptest.NewRequest("POST", "/hooks/awx?modes=prometheus", strings.NewReader("{}"))
+		request.Header.Set("X-Webhook-Secret", "secret")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusAccepted {
+			t.Fatalf("The notification should be accepted, got %d", recorder.Code)
+		}
+	}
+	<-generated
+	time.Sleep(100 * time.Millisecond)
+	if len(generated) != 0 {
+		t.Errorf("The burst should trigger a single regeneration")
+	}
+	request := httptest.NewRequest("POST", "/hooks/awx?modes=unknown", strings.NewReader("{}"))
+	request.Header.Set("X-Webhook-Secret", "secret")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Unknown modes should not be accepted")
+	}
+}
+
+/// TestWebhookAffectedModes Tests the modes that are regenerated for the types of the AWX notifications
+func TestWebhookAffectedModes(t *testing.T) {
+	cache := newSDCache()
+	config := Config{}
+	handler := newWebhookHandler(config, cache)
+	for body, expected := range map[string][]string{
+		`{"id": 1, "status": "successful"}`: {"blackbox", "probe", "prometheus"},
+		`{"type": "inventory_update"}`:      {"blackbox", "probe", "prometheus"},
+		`{"id": 1, "playbook": "site.yml"}`: nil,
+		`{"type": "project_update"}`:        nil,
+		`not json`:                          {"blackbox", "probe", "prometheus"},
+	} {
+		names := handler.getAffectedModes([]byte(body))
+		sort.Strings(names)
+		if reflect.DeepEqual(names, expected) == false {
+			t.Errorf("Expected the modes %v for %s, got %v", expected, body, names)
+		}
+	}
+	handler.config.blackbox.loadFacts = true
+	if names := handler.getAffectedModes([]byte(`{"type": "job"}`)); reflect.DeepEqual(names, []string{"blackbox", "probe"}) == false {
+		t.Errorf("The jobs should regenerate the modes with facts, got %v", names)
+	}
+	request := httptest.NewRequest("POST", "/hooks/awx", strings.NewReader(`{"type": "system_job"}`))
+	request.Header.Set("X-Webhook-Secret", "")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("The notifications without affected modes should be ignored, got %d", recorder.Code)
+	}
+}
+
+/// TestInventorySyncer Tests the full and the incremental sync of the inventory model
+func TestInventorySyncer(t *testing.T) {
+	now := time.Now().UTC()
+	hosts := map[int]map[string]interface{}{
+		1: {"id": 1, "name": "web1", "modified": now.Add(-time.Hour).Format(time.RFC3339), "related": map[string]string{"variable_data": "/api/v2/hosts/1/variable_data/"}},
+		2: {"id": 2, "name": "web2", "modified": now.Add(-time.Hour).Format(time.RFC3339), "related": map[string]string{"variable_data": "/api/v2/hosts/2/variable_data/"}},
+	}
+	group := map[string]interface{}{
+		"id": 1, "name": "web", "modified": now.Add(-time.Hour).Format(time.RFC3339),
+		"related": map[string]string{"variable_data": "/api/v2/groups/1/variable_data/", "hosts": "/api/v2/groups/1/hosts/"},
+	}
+	members := []int{1, 2}
+	var activityStream, jobHosts []map[string]interface{}
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/"), "/")
+		requests[path]++
+		modifiedAfter, _ := time.Parse(time.RFC3339, r.URL.Query().Get("modified__gt"))
+		list := func(objects []map[string]interface{}) {
+			var results []map[string]interface{}
+			for _, object := range objects {
+				modified, _ := time.Parse(time.RFC3339, fmt.Sprintf("%v", object["modified"]))
+				if modified.After(modifiedAfter) {
+					results = append(results, object)
+				}
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": len(results), "results": results})
+		}
+		var hostList []map[string]interface{}
+		for _, id := range []int{1, 2, 3} {
+			if host, ok := hosts[id]; ok {
+				hostList = append(hostList, host)
+			}
+		}
+		switch {
+		case path == "groups":
+			list([]map[string]interface{}{group})
+		case path == "groups/1":
+			_ = json.NewEncoder(w).Encode(group)
+		case path == "groups/1/variable_data":
+			_, _ = w.Write([]byte(`{"prometheus_config": [{"name": "node", "port": 9100}]}`))
+		case path == "groups/1/hosts":
+			var memberList []map[string]interface{}
+			for _, id := range members {
+				memberList = append(memberList, hosts[id])
+			}
+			list(memberList)
+		case path == "hosts" && r.URL.Query().Get("last_job__finished__gt") != "":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": len(jobHosts), "results": jobHosts})
+		case path == "hosts":
+			list(hostList)
+		case strings.HasSuffix(path, "/variable_data"):
+			var id int
+			_, _ = fmt.Sscanf(path, "hosts/%d/variable_data", &id)
+			_, _ = fmt.Fprintf(w, `{"ansible_host": "10.0.0.%d"}`, id)
+		case strings.HasPrefix(path, "hosts/"):
+			var id int
+			_, _ = fmt.Sscanf(path, "hosts/%d", &id)
+			if host, ok := hosts[id]; ok {
+				_ = json.NewEncoder(w).Encode(host)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case path == "activity_stream":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": len(activityStream), "results": activityStream})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	config := Config{}
+	config.awx.Host = server.URL
+	config.awx.Timeout = time.Second
+	config.prometheus.configName = "prometheus_config"
+	config.prometheus.IpVar = "ansible_host"
+	config.sync.incremental = true
+	config.sync.fullSyncInterval = time.Hour
+	syncer := newInventorySyncer()
+	getTargets := func() []string {
+		prometheusHosts, err := syncer.source(config).PrometheusHosts(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var targets []string
+		for _, prometheusHost := range prometheusHosts {
+			targets = append(targets, prometheusHost.Targets...)
+		}
+		return targets
+	}
+	if targets := getTargets(); reflect.DeepEqual(targets, []string{"10.0.0.1:9100", "10.0.0.2:9100"}) == false {
+		t.Errorf("The full sync created the wrong targets: %v", targets)
+	}
+	// web2 is deleted and web3 is created and added to the group
+	delete(hosts, 2)
+	hosts[3] = map[string]interface{}{"id": 3, "name": "web3", "modified": now.Format(time.RFC3339), "related": map[string]string{"variable_data": "/api/v2/hosts/3/variable_data/"}}
+	members = []int{1, 3}
+	activityStream = []map[string]interface{}{
+		{"id": 1, "operation": "delete", "object1": "host", "changes": map[string]interface{}{"id": 2}},
+		{"id": 2, "operation": "associate", "object1": "host", "object2": "group", "summary_fields": map[string]interface{}{
+			"host":  []map[string]interface{}{{"id": 3, "name": "web3"}},
+			"group": []map[string]interface{}{{"id": 1, "name": "web"}},
+		}},
+	}
+	if targets := getTargets(); reflect.DeepEqual(targets, []string{"10.0.0.1:9100", "10.0.0.3:9100"}) == false {
+		t.Errorf("The incremental sync created the wrong targets: %v", targets)
+	}
+	if requests["hosts/1/variable_data"] != 1 {
+		t.Errorf("The not modified hosts should not be fetched again")
+	}
+	// A failed job of web1 does not change its modification time
+	activityStream = nil
+	jobHosts = []map[string]interface{}{{
+		"id": 1, "name": "web1", "modified": now.Add(-time.Hour).Format(time.RFC3339), "has_active_failures": true, "last_job": 7,
+		"summary_fields": map[string]interface{}{"last_job": map[string]interface{}{"id": 7, "status": "failed", "failed": true}},
+	}}
+	getTargets()
+	host := syncer.model.hosts[1].Host
+	if host.HasActiveFailures == false || host.LastJob != 7 || host.SummaryFields.LastJob.Status != "failed" {
+		t.Errorf("The incremental sync should refresh the last job of the hosts: %+v", host)
+	}
+	if requests["hosts/1/variable_data"] != 1 {
+		t.Errorf("The job runs should not fetch the variables of the hosts again")
+	}
+}
+
+/// TestLoadSource Tests that the inventory is only loaded once for several modes
+func TestLoadSource(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"count": 0, "results": []}`))
+	}))
+	defer server.Close()
+	config := Config{}
+	config.awx.Host = server.URL
+	config.awx.Timeout = time.Second
+	modes := getModes()
+	source, err := loadSource(config, modes[:1])
+	if _, ok := source.(awxSource); !ok || err != nil || requests != 0 {
+		t.Errorf("A single mode should query AWX directly")
+	}
+	source, err = loadSource(config, modes)
+	if _, ok := source.(*InventoryModel); !ok || err != nil || requests != 2 {
+		t.Errorf("Several modes should share the inventory model: %v", err)
+	}
+}
+
+/// TestAWXSourcePrometheusHosts Tests that the single mode runs read all the pages of the group hosts and
+/// traverse the groups in the order of the inventory model
+func TestAWXSourcePrometheusHosts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/"), "/")
+		switch {
+		case path == "groups":
+			_, _ = w.Write([]byte(`{"count": 2, "results": [
+				{"id": 2, "name": "web", "related": {"variable_data": "/api/v2/groups/2/variable_data/", "hosts": "/api/v2/groups/2/hosts/"}},
+				{"id": 1, "name": "app", "related": {"variable_data": "/api/v2/groups/1/variable_data/", "hosts": "/api/v2/groups/1/hosts/"}}]}`))
+		case strings.HasPrefix(path, "groups/") && strings.HasSuffix(path, "/variable_data"):
+			_, _ = w.Write([]byte(`{"prometheus_config": [{"name": "node", "port": 9100}]}`))
+		case strings.HasPrefix(path, "groups/") && strings.HasSuffix(path, "/hosts"):
+			id, next := 1, "/api/v2/"+path+"/?page=2"
+			if r.URL.Query().Get("page") == "2" {
+				id, next = 2, ""
+			}
+			_, _ = fmt.Fprintf(w, `{"count": 2, "next": %q, "results": [{"id": %d, "name": "host%d", "related": {"variable_data": "/api/v2/hosts/%d/variable_data/"}}]}`, next, id, id, id)
+		case path == "hosts":
+			_, _ = w.Write([]byte(`{"count": 2, "results": [
+				{"id": 1, "name": "host1", "related": {"variable_data": "/api/v2/hosts/1/variable_data/"}},
+				{"id": 2, "name": "host2", "related": {"variable_data": "/api/v2/hosts/2/variable_data/"}}]}`))
+		case strings.HasSuffix(path, "/variable_data"):
+			var id int
+			_, _ = fmt.Sscanf(path, "hosts/%d/variable_data", &id)
+			_, _ = fmt.Fprintf(w, `{"ansible_host": "10.0.0.%d"}`, id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	config := Config{}
+	config.awx.Host = server.URL
+	config.awx.Timeout = time.Second
+	config.prometheus.configName = "prometheus_config"
+	config.prometheus.IpVar = "ansible_host"
+	awxHosts, err := awxSource{}.PrometheusHosts(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := loadInventoryModel(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modelHosts, _ := model.PrometheusHosts(config)
+	if len(awxHosts) != 4 || reflect.DeepEqual(awxHosts, modelHosts) == false {
+		t.Errorf("The single mode and the model should create the same targets, got %v and %v", awxHosts, modelHosts)
+	}
+}
+
+/// TestAWXSourceBlackboxGroups Tests that the single mode runs and the model select the same group of the host
+/// entries, independent of the order of the groups in AWX
+func TestAWXSourceBlackboxGroups(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/"), "/")
+		switch path {
+		case "groups":
+			_, _ = w.Write([]byte(`{"count": 2, "results": [
+				{"id": 1, "name": "web", "related": {"variable_data": "/api/v2/groups/1/variable_data/", "hosts": "/api/v2/groups/1/hosts/"}},
+				{"id": 2, "name": "app", "related": {"variable_data": "/api/v2/groups/2/variable_data/", "hosts": "/api/v2/groups/2/hosts/"}}]}`))
+		case "groups/1/variable_data", "groups/2/variable_data":
+			_, _ = w.Write([]byte(`{}`))
+		case "groups/1/hosts", "groups/2/hosts", "hosts":
+			_, _ = w.Write([]byte(`{"count": 1, "results": [{"id": 1, "name": "host1",
+				"related": {"variable_data": "/api/v2/hosts/1/variable_data/"},
+				"summary_fields": {"groups": {"count": 2, "results": [{"id": 1, "name": "web"}, {"id": 2, "name": "app"}]}}}]}`))
+		case "hosts/1/variable_data":
+			_, _ = w.Write([]byte(`{"ansible_host": "10.0.0.1", "blackbox_config": [{"module": "icmp"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	config := Config{}
+	config.awx.Host = server.URL
+	config.awx.Timeout = time.Second
+	config.blackbox.configName = "blackbox_config"
+	config.blackbox.IpVar = "ansible_host"
+	config.blackbox.groupStrategy = groupStrategyFirst
+	awxHosts, err := awxSource{}.BlackboxHosts(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := loadInventoryModel(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modelHosts, _ := model.BlackboxHosts(config)
+	sortBlackboxHosts(awxHosts)
+	sortBlackboxHosts(modelHosts)
+	if len(awxHosts) != 1 || awxHosts[0].Labels.Group != "app" || reflect.DeepEqual(awxHosts, modelHosts) == false {
+		t.Errorf("The single mode and the model should select the group app, got %v and %v", awxHosts, modelHosts)
+	}
+}
+
+/// TestLoadConfigurationOverrides Tests the overrides of the configuration from the environment and the flags
+func TestLoadConfigurationOverrides(t *testing.T) {
+	t.Setenv("AWX_EXPORTER_AWX_HOSTNAME", "https://env")
+	t.Setenv("AWX_EXPORTER_SERVER_LISTENADDRESS", ":9999")
+	overrides := ConfigOverrides{}
+	if overrides.Set("AWX.HostName=https://flag") != nil || overrides.Set("invalid") == nil {
+		t.Errorf("The overrides are not parsed correctly")
+	}
+	config, err := loadConfiguration("config.ini.dist", overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.awx.Host != "https://flag" {
+		t.Errorf("The flag should override the environment, got %s", config.awx.Host)
+	}
+	if config.server.listenAddress != ":9999" {
+		t.Errorf("The environment should override the config, got %s", config.server.listenAddress)
+	}
+	if config.prometheus.configName != "prometheus_config" {
+		t.Errorf("The not overridden keys should be kept")
+	}
+	if len(config.blackbox.probers) != 0 || len(config.probe.probes) != 0 {
+		t.Errorf("The distributed config should not define probers or probes, got %v", config.blackbox.probers)
+	}
+}
+
+/// TestLoadConfigurationNames Tests that the prober and probe names keep their case
+func TestLoadConfigurationNames(t *testing.T) {
+	content, err := os.ReadFile("config.ini.dist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = bytes.Replace(content, []byte("[PROBERS]\n"), []byte("[PROBERS]\nDMZ=dmz:9115\n\n[PROBE.SNMP]\nExporterAddress=snmp-exporter:9116\n"), 1)
+	configPath := filepath.Join(t.TempDir(), "config.ini")
+	err = os.WriteFile(configPath, content, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides := ConfigOverrides{"BLACKBOX.DefaultProbers=dmz", "probers.Cloud=cloud:9115"}
+	config, err := loadConfiguration(configPath, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(config.blackbox.probers, map[string]string{"DMZ": "dmz:9115", "Cloud": "cloud:9115"}) == false {
+		t.Errorf("The prober names should keep their case, got %v", config.blackbox.probers)
+	}
+	if len(config.probe.probes) != 1 || config.probe.probes[0].name != "SNMP" || config.probe.probes[0].metricsPath != "/SNMP" {
+		t.Errorf("The probe names should keep their case, got %v", config.probe.probes)
+	}
+	probers := getEntryProbers(config, map[string]interface{}{}, []interface{}{"dmz", "CLOUD"})
+	if reflect.DeepEqual(probers, []string{"DMZ", "Cloud"}) == false {
+		t.Errorf("The probers should be selected case insensitive, got %v", probers)
+	}
+	if probers = getEntryProbers(config, map[string]interface{}{}, nil); reflect.DeepEqual(probers, []string{"DMZ"}) == false {
+		t.Errorf("The default probers should be selected case insensitive, got %v", probers)
+	}
+}
+
+/// TestRunCommandInvalidFlags Tests that the invalid commands and flag combinations are rejected
+func TestRunCommandInvalidFlags(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"unknown"},
+		{"-config-path=config.ini.dist"},
+		{"prometheus", "-force", "-watch"},
+		{"prometheus", "-interval=1m"},
+		{"blackbox", "unexpected"},
+	} {
+		if exitCode := runCommand(args); exitCode != 2 {
+			t.Errorf("The arguments %v should be rejected, got exit code %d", args, exitCode)
+		}
+	}
+}
+
+/// TestExplainHost Tests the explanation of the targets of a host
+func TestExplainHost(t *testing.T) {
+	config := Config{}
+	config.prometheus.configName = "prometheus_config"
+	config.prometheus.IpVar = "ansible_host"
+	config.prometheus.configHostOverride = true
+	config.blackbox.configName = "blackbox_config"
+	config.blackbox.IgnoredGroups = []string{"cmdb_imported"}
+	config.alertmanager.configName = "alertmanager_config"
+	host := Host{ID: 1, Name: "web1"}
+	host.SummaryFields.Groups.Results = []GroupSummary{{ID: 2, Name: "cmdb_imported"}, {ID: 1, Name: "web"}}
+	model := newInventoryModel()
+	model.hosts[1] = &ModelHost{Host: host, Variables: map[string]interface{}{
+		"ansible_host":      "10.0.0.1",
+		"prometheus_config": []interface{}{map[string]interface{}{"name": "node", "port": float64(9200)}},
+		"blackbox_config":   []interface{}{map[string]interface{}{"module": "http_2xx", "targets": []interface{}{"https://web1"}}},
+	}}
+	model.groups[1] = &ModelGroup{Group: Group{ID: 1, Name: "web"}, HostIDs: []int{1}, Variables: map[string]interface{}{
+		"prometheus_config": []interface{}{map[string]interface{}{"name": "node", "port": float64(9100)}},
+	}}
+	model.groups[2] = &ModelGroup{Group: Group{ID: 2, Name: "cmdb_imported"}, HostIDs: []int{1}}
+	var out strings.Builder
+	explainHost(&out, config, model, model.findHosts(config, "web1")[0])
+	for _, expected := range []string{
+		"Groups: cmdb_imported, web",
+		"group web provides",
+		"host variable overrides it (ConfigHostOverride=true)",
+		"targets 10.0.0.1:9200",
+		"group cmdb_imported is ignored (IgnoredGroups)",
+		"group web is used",
+		"targets https://web1",
+		"no group of the host has alertmanager_config",
+	} {
+		if strings.Contains(out.String(), expected) == false {
+			t.Errorf("The explanation does not contain %q:\n%s", expected, out.String())
+		}
+	}
+}
+
+/// TestCreateCoverageReport Tests the hosts and groups without monitoring
+func TestCreateCoverageReport(t *testing.T) {
+	config := Config{}
+	config.prometheus.configName = "prometheus_config"
+	config.blackbox.configName = "blackbox_config"
+	config.alertmanager.configName = "alertmanager_config"
+	inventory := InventorySummary{Name: "servers", OrganizationId: 1}
+	model := newInventoryModel()
+	for id, name := range map[int]string{1: "web1", 2: "web2"} {
+		host := Host{ID: id, Name: name}
+		host.SummaryFields.Inventory = inventory
+		host.SummaryFields.Groups.Results = []GroupSummary{{ID: 1, Name: "web"}}
+		model.hosts[id] = &ModelHost{Host: host, Variables: map[string]interface{}{}}
+	}
+	model.hosts[1].Variables["blackbox_config"] = []interface{}{map[string]interface{}{"module": "icmp", "targets": []interface{}{"web1"}}}
+	group := Group{ID: 1, Name: "web"}
+	group.SummaryFields.Inventory = inventory
+	model.groups[1] = &ModelGroup{Group: group, HostIDs: []int{1, 2}, Variables: map[string]interface{}{
+		"prometheus_config": []interface{}{map[string]interface{}{"name": "node", "port": float64(9100)}},
+	}}
+	report := createCoverageReport(config, model)
+	expected := CoverageSummary{
+		OrganizationId:            1,
+		Inventory:                 "servers",
+		Hosts:                     2,
+		HostsWithoutPrometheus:    0,
+		HostsWithoutBlackbox:      1,
+		Groups:                    1,
+		GroupsWithoutAlertmanager: 1,
+	}
+	if len(report.Summary) != 1 || report.Summary[0] != expected {
+		t.Errorf("The summary is not valid: %+v", report.Summary)
+	}
+	var out strings.Builder
+	if err := writeCoverageReport(&out, report, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "1,servers,host,web2,blackbox") == false || strings.Contains(out.String(), "1,servers,group,web,alertmanager") == false {
+		t.Errorf("The csv report is not valid:\n%s", out.String())
+	}
+	if writeCoverageReport(&out, report, "xml") == nil {
+		t.Errorf("The unknown formats should be rejected")
+	}
+}
+
+/// TestCreateCoverageReportSkippedTargets Tests that the hosts whose entries are all skipped by the generators
+/// are not covered
+func TestCreateCoverageReportSkippedTargets(t *testing.T) {
+	config := Config{}
+	config.prometheus.configName = "prometheus_config"
+	config.blackbox.configName = "blackbox_config"
+	config.alertmanager.configName = "alertmanager_config"
+	model := newInventoryModel()
+	model.hosts[1] = &ModelHost{Host: Host{ID: 1, Name: "web1"}, Variables: map[string]interface{}{
+		"blackbox_config": []interface{}{
+			map[string]interface{}{"module": "http_2xx", "targets": []interface{}{"htps://web1/", map[string]interface{}{"module": "icmp"}}},
+		},
+	}}
+	model.groups[1] = &ModelGroup{Group: Group{ID: 1, Name: "web"}, HostIDs: []int{1}, Variables: map[string]interface{}{
+		"prometheus_config":   []interface{}{"port: 9100"},
+		"alertmanager_config": []interface{}{map[string]interface{}{"type": "email", "name": "ops"}},
+	}}
+	before := getValidationErrorCount()
+	report := createCoverageReport(config, model)
+	if len(report.Summary) != 1 || report.Summary[0].HostsWithoutPrometheus != 1 || report.Summary[0].HostsWithoutBlackbox != 1 || report.Summary[0].GroupsWithoutAlertmanager != 0 {
+		t.Errorf("The skipped entries should not cover the host, got %+v", report.Summary)
+	}
+	if getValidationErrorCount() != before {
+		t.Errorf("The coverage report should not count the validation errors again")
+	}
+	recordCoverage(report)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(coverageCollector)
+	families, err := registry.Gather()
+	if err != nil || len(families) != 4 {
+		t.Errorf("Expected the 4 coverage metrics, got %d: %v", len(families), err)
+	}
+}
+
+/// TestCreateHostBlackboxHosts Tests the inheritance of the group blackbox configs and the host entries on top
+func TestCreateHostBlackboxHosts(t *testing.T) {
+	config := Config{}
+	config.blackbox.configName = "blackbox_config"
+	config.blackbox.IpVar = "ansible_host"
+	config.blackbox.IgnoredGroups = []string{"cmdb_imported"}
+	host := Host{ID: 1, Name: "web1"}
+	host.SummaryFields.Groups.Results = []GroupSummary{{Name: "cmdb_imported"}, {Name: "dmz"}}
+	hostVariables := map[string]interface{}{
+		"ansible_host": "10.0.0.1",
+		"blackbox_config": []interface{}{
+			map[string]interface{}{"module": "http_2xx", "targets": []interface{}{"https://web1"}},
+			map[string]interface{}{"module": "tcp_connect", "targets": []interface{}{"10.0.0.1:22"}},
+		},
+	}
+	groupConfigs := []BlackboxGroupConfig{{Group: "web", Config: []interface{}{
+		map[string]interface{}{"module": "icmp"},
+		map[string]interface{}{"module": "http_2xx", "targets": []interface{}{"https://web"}},
+	}}}
+	var result []string
+	for _, blackboxHost := range createHostBlackboxHosts(config, host, []string{"cmdb_imported", "dmz"}, hostVariables, groupConfigs, nil) {
+		result = append(result, fmt.Sprintf("%s/%s/%s", blackboxHost.Labels.Group, blackboxHost.Labels.Module, strings.Join(blackboxHost.Targets, ",")))
+	}
+	expected := []string{"web/icmp/10.0.0.1", "web/http_2xx/https://web1", "dmz/tcp_connect/10.0.0.1:22"}
+	if reflect.DeepEqual(result, expected) == false {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+func TestRenderBlackboxTargets(t *testing.T) {
+	config := Config{}
+	config.blackbox.configName = "blackbox_config"
+	config.blackbox.IpVar = "ansible_host"
+	host := Host{ID: 1, Name: "web1"}
+	facts := map[string]interface{}{"fqdn": "web1.example.com"}
+	hostVariables := getTemplateVariables(host, map[string]interface{}{
+		"ansible_host": "10.0.0.1",
+		"cmdb_name":    "web1.uni-wuerzburg.de",
+		"ssh_port":     float64(2222),
+	}, facts)
+	groupConfigs := []BlackboxGroupConfig{{Group: "web", Config: []interface{}{
+		map[string]interface{}{"module": "http_2xx", "targets": []interface{}{"https://{{ cmdb_name }}/health", "https://{{missing}}/"}},
+		map[string]interface{}{"module": "ssh_banner", "targets": []interface{}{"{{ ansible_host }}:{{ ssh_port }}", "{{ ansible_facts.fqdn }}:22"}},
+		map[string]interface{}{"module": "icmp", "targets": []interface{}{"{{ inventory_hostname }}"}},
+	}}}
+	before := getValidationErrorCount()
+	var result []string
+	for _, blackboxHost := range createHostBlackboxHosts(config, host, []string{"web"}, hostVariables, groupConfigs, nil) {
+		result = append(result, fmt.Sprintf("%s/%s", blackboxHost.Labels.Module, strings.Join(blackboxHost.Targets, ",")))
+	}
+	expected := []string{"http_2xx/https://web1.uni-wuerzburg.de/health", "ssh_banner/10.0.0.1:2222,web1.example.com:22", "icmp/web1"}
+	if reflect.DeepEqual(result, expected) == false {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+	if getValidationErrorCount()-before != 1 {
+		t.Errorf("Expected the target with the undefined variable to be counted as validation error")
+	}
+}
+
+/// TestSelectBlackboxGroups Tests the group strategies and the patterns of the ignored groups
+func TestSelectBlackboxGroups(t *testing.T) {
+	groups := []string{"cmdb_imported", "dmz", "sles12_64Guest", "web"}
+	for _, test := range []struct {
+		strategy  string
+		preferred []string
+		ignored   []string
+		fallback  string
+		expected  []string
+	}{
+		{"first", nil, []string{"cmdb_imported", "sles*"}, "", []string{"dmz"}},
+		{"priority", []string{"/^w.b$/", "dmz"}, []string{"cmdb_imported"}, "", []string{"web"}},
+		{"priority", []string{"db*"}, []string{"cmdb_imported"}, "", []string{"dmz"}},
+		{"all", nil, []string{"cmdb_imported", "/Guest$/"}, "", []string{"dmz", "web"}},
+		{"first", nil, []string{"*"}, "", nil},
+		{"first", nil, []string{"*"}, "unassigned", []string{"unassigned"}},
+	} {
+		config := Config{}
+		config.blackbox.groupStrategy = test.strategy
+		config.blackbox.preferredGroups = test.preferred
+		config.blackbox.IgnoredGroups = test.ignored
+		config.blackbox.fallbackGroup = test.fallback
+		result := selectBlackboxGroups(config, groups)
+		if reflect.DeepEqual(result, test.expected) == false {
+			t.Errorf("Expected %v with the strategy %s, got %v", test.expected, test.strategy, result)
+		}
+	}
+	if validateGroupPatterns("BLACKBOX.IgnoredGroups", []string{"/(/"}) == nil {
+		t.Errorf("Expected an error for the invalid regular expression")
+	}
+}
+
+/// TestMergeBlackboxModules Tests that the AWX modules are merged in the blackbox config
+func TestMergeBlackboxModules(t *testing.T) {
+	source := `
+modules:
+  icmp:
+    prober: icmp
+  http_2xx:
+    prober: http
+    timeout: 5s
+`
+	var blackboxFileConfig yaml.MapSlice
+	err := yaml.Unmarshal([]byte(source), &blackboxFileConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{}
+	config.blackbox.modulesConfigName = "blackbox_modules"
+	modules := createBlackboxModules(config, "web", map[string]interface{}{
+		"http_2xx":   map[string]interface{}{"prober": "http", "timeout": "10s"},
+		"ssh_banner": map[string]interface{}{"prober": "tcp"},
+		"invalid":    map[string]interface{}{"timeout": "10s"},
+	}, nil)
+	modules = createBlackboxModules(config, "db", map[string]interface{}{
+		"ssh_banner": map[string]interface{}{"prober": "tcp"},
+	}, modules)
+	if len(modules) != 2 {
+		t.Fatalf("Expected the modules http_2xx and ssh_banner, got %v", modules)
+	}
+	merged, err := mergeBlackboxModules(BlackboxFileConfig(blackboxFileConfig), modules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		Modules yaml.MapSlice `yaml:"modules"`
+	}
+	err = yaml.Unmarshal([]byte(merged.String()), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, module := range result.Modules {
+		names = append(names, fmt.Sprintf("%v", module.Key))
+	}
+	if reflect.DeepEqual(names, []string{"icmp", "http_2xx", "ssh_banner"}) == false {
+		t.Errorf("Expected the modules icmp, http_2xx and ssh_banner, got %v", names)
+	}
+	if strings.Contains(merged.String(), "timeout: 10s") == false {
+		t.Errorf("Expected the http_2xx module to be updated:\n%s", merged.String())
+	}
+	blackboxHosts := []BlackboxHost{{Labels: BlackboxHostLabel{Module: "icmp"}}, {Labels: BlackboxHostLabel{Module: "tcp_connect"}}}
+	err = checkBlackboxModules(merged, blackboxHosts)
+	if err == nil || strings.Contains(err.Error(), "tcp_connect") == false {
+		t.Errorf("Expected an error for the undefined module tcp_connect, got %v", err)
+	}
+}
+
+/// TestCreateCombinedBlackboxScrapeConfig Tests the single blackbox job for all the modules
+func TestCreateCombinedBlackboxScrapeConfig(t *testing.T) {
+	config := Config{}
+	config.blackbox.exporterAddress = "blackbox:9115"
+	config.blackbox.scrapeJobs = blackboxScrapeJobsCombined
+	config.blackbox.jobName = "probes"
+	blackboxHosts := []BlackboxHost{
+		{Labels: BlackboxHostLabel{Group: "web", Host: "web1", Module: "http_2xx"}, Targets: []string{"https://web1/"}},
+		{Labels: BlackboxHostLabel{Group: "web", Host: "web1", Module: "icmp"}, Targets: []string{"10.0.0.1"}},
+	}
+	scrapeConfigs := createBlackboxScrapeConfigs(config, blackboxHosts)
+	if len(scrapeConfigs) != 1 || scrapeConfigs[0].JobName != "probes" {
+		t.Fatalf("Expected a single job probes, got %v", scrapeConfigs)
+	}
+	if len(scrapeConfigs[0].Params) != 0 || len(scrapeConfigs[0].StaticConfigs) != 2 {
+		t.Errorf("Expected the static configs of both modules without module param")
+	}
+	expected := []RelabelConfig{
+		{SourceLabels: []string{"module"}, TargetLabel: "__param_module"},
+		{SourceLabels: []string{"__address__"}, TargetLabel: "__param_target"},
+		{SourceLabels: []string{"__param_target"}, TargetLabel: "instance"},
+		{TargetLabel: "__address__", Replacement: "blackbox:9115"},
+	}
+	if reflect.DeepEqual(scrapeConfigs[0].RelabelConfigs, expected) == false {
+		t.Errorf("Expected the relabel configs %v, got %v", expected, scrapeConfigs[0].RelabelConfigs)
+	}
+}
+
+/// TestBlackboxProbers Tests the targets per prober from the entry, the group default and the DefaultProbers
+func TestBlackboxProbers(t *testing.T) {
+	config := Config{}
+	config.blackbox.configName = "blackbox_config"
+	config.blackbox.exporterAddress = "blackbox:9115"
+	config.blackbox.probers = map[string]string{"campus": "campus:9115", "dmz": "dmz:9115", "cloud": "cloud:9115"}
+	config.blackbox.defaultProbers = []string{"campus"}
+	host := Host{ID: 1, Name: "web1"}
+	hostVariables := map[string]interface{}{
+		"ansible_host": "10.0.0.1",
+		"blackbox_config": []interface{}{
+			map[string]interface{}{"module": "tcp_connect", "targets": []interface{}{"10.0.0.1:22"}},
+		},
+	}
+	groupConfigs := []BlackboxGroupConfig{
+		{Group: "web", Config: []interface{}{
+			map[string]interface{}{"module": "http_2xx", "targets": []interface{}{"https://web1"}, "probers": "all"},
+			map[string]interface{}{"module": "icmp", "targets": []interface{}{"10.0.0.1"}, "probers": []interface{}{"dmz", "unknown"}},
+		}},
+		{Group: "dmz", Probers: []interface{}{"dmz"}},
+		{Group: "other", Config: []interface{}{
+			map[string]interface{}{"module": "dns", "targets": []interface{}{"10.0.0.1"}},
+		}},
+	}
+	var result []string
+	for _, blackboxHost := range createHostBlackboxHosts(config, host, []string{"dmz"}, hostVariables, groupConfigs, nil) {
+		result = append(result, fmt.Sprintf("%s/%s/%s", blackboxHost.Labels.Module, blackboxHost.Labels.Prober, blackboxHost.Labels.ProberAddress))
+	}
+	expected := []string{
+		"http_2xx/campus/campus:9115", "http_2xx/cloud/cloud:9115", "http_2xx/dmz/dmz:9115",
+		"icmp/dmz/dmz:9115",
+		"dns/campus/campus:9115",
+		"tcp_connect/dmz/dmz:9115",
+	}
+	if reflect.DeepEqual(result, expected) == false {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+	scrapeConfigs := createBlackboxScrapeConfigs(config, createHostBlackboxHosts(config, host, []string{"dmz"}, hostVariables, groupConfigs, nil))
+	relabelConfigs := scrapeConfigs[0].RelabelConfigs
+	if relabelConfigs[len(relabelConfigs)-1].SourceLabels[0] != "__prober_address" {
+		t.Errorf("The address is not replaced with the address of the prober")
+	}
+}
+
+/// TestBlackboxScrapeConfigsWithoutExporterAddress Tests that the ExporterAddress is only needed for the targets
+/// without prober
+func TestBlackboxScrapeConfigsWithoutExporterAddress(t *testing.T) {
+	config := Config{}
+	config.blackbox.configName = "blackbox_config"
+	config.blackbox.probers = map[string]string{"dmz": "dmz:9115"}
+	entry := map[string]interface{}{"module": "icmp", "targets": []interface{}{"10.0.0.1"}, "probers": "all"}
+	model := newInventoryModel()
+	model.hosts[1] = &ModelHost{Host: Host{ID: 1, Name: "web1"}, Variables: map[string]interface{}{"blackbox_config": []interface{}{entry}}}
+	model.groups[1] = &ModelGroup{Group: Group{ID: 1, Name: "web"}, HostIDs: []int{1}}
+	content, err := createBlackboxScrapeConfigsOutput(config, model)
+	if err != nil || strings.Contains(string(content), "dmz") == false {
+		t.Errorf("The targets with probers should not need the ExporterAddress, got %v:\n%s", err, content)
+	}
+	delete(entry, "probers")
+	if _, err := createBlackboxScrapeConfigsOutput(config, model); err == nil {
+		t.Errorf("Expected an error for the targets without prober and ExporterAddress")
+	}
+}
+
+/// TestBlackboxTargetObjects Tests the target objects with their own module, labels, interval and prober
+func TestBlackboxTargetObjects(t *testing.T) {
+	config := Config{}
+	config.blackbox.configName = "blackbox_config"
+	config.blackbox.HostNameVar = "cmdb_name"
+	config.blackbox.probers = map[string]string{"dmz": "dmz:9115"}
+	hostVariables := map[string]interface{}{"cmdb_name": "web1.example.com"}
+	entry := map[string]interface{}{
+		"module": "http_2xx",
+		"targets": []interface{}{
+			"https://{{ cmdb_name }}/",
+			map[string]interface{}{
+				"url":      "{{ cmdb_name }}:22",
+				"module":   "ssh_banner",
+				"labels":   map[string]interface{}{"service": "ssh", "severity": "critical", "job": "other"},
+				"interval": "5m",
+				"prober":   "dmz",
+			},
+			map[string]interface{}{"module": "dns"},
+		},
+	}
+	before := getValidationErrorCount()
+	blackboxHosts, ok := createBlackboxHost(config, "web", hostVariables, entry, nil)
+	if !ok || len(blackboxHosts) != 2 {
+		t.Fatalf("Expected the entry host and the target object host, got %v", blackboxHosts)
+	}
+	if getValidationErrorCount()-before != 2 {
+		t.Errorf("Expected the reserved label and the target without url to be counted as validation errors")
+	}
+	if reflect.DeepEqual(blackboxHosts[0].Targets, []string{"https://web1.example.com/"}) == false || blackboxHosts[0].Labels.Module != "http_2xx" {
+		t.Errorf("The string target is not kept in the entry host: %v", blackboxHosts[0])
+	}
+	out, err := json.Marshal(blackboxHosts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"labels":{"__prober_address":"dmz:9115","__scrape_interval__":"5m","group":"web","host":"web1.example.com","ip":"","job":"blackbox","module":"ssh_banner","prober":"dmz","service":"ssh","severity":"critical"},"targets":["web1.example.com:22"]}`
+	if string(out) != expected {
+		t.Errorf("Expected %s, got %s", expected, out)
+	}
+}
+
+/// TestTLSTargets Tests the tls targets extracted from the host variables
+func TestTLSTargets(t *testing.T) {
+	config := Config{}
+	config.blackbox.configName = "blackbox_config"
+	config.blackbox.tlsVariables = []string{"nginx_vhosts[].server_name", "letsencrypt_domains", "missing[].name"}
+	config.blackbox.tlsModule = "tls_connect"
+	config.blackbox.tlsPort = 443
+	host := Host{ID: 1, Name: "web1"}
+	hostVariables := map[string]interface{}{
+		"nginx_vhosts": []interface{}{
+			map[string]interface{}{"server_name": "www.example.com example.com"},
+			map[string]interface{}{"server_name": "_"},
+			map[string]interface{}{"server_name": "*.example.com"},
+			map[string]interface{}{"listen": 80},
+		},
+		"letsencrypt_domains": []interface{}{"example.com", "mail.example.com:465"},
+	}
+	var result []string
+	for _, blackboxHost := range createHostBlackboxHosts(config, host, []string{"web"}, hostVariables, nil, nil) {
+		result = append(result, fmt.Sprintf("%s/%s/%s", blackboxHost.Labels.Group, blackboxHost.Labels.Module, strings.Join(blackboxHost.Targets, ",")))
+	}
+	expected := []string{"web/tls_connect/www.example.com:443,example.com:443,mail.example.com:465"}
+	if reflect.DeepEqual(result, expected) == false {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+}
+
+/// TestProbeModes Tests the targets and jobs of the [PROBE.<name>] exporters
+func TestProbeModes(t *testing.T) {
+	cfg, err := ini.LoadSources(ini.LoadOptions{Insensitive: true}, []byte(`
+[PROBE.snmp]
+ConfigName=snmp_config
+ExporterAddress=snmp-exporter:9116
+Params=module,auth
+Labels=team=network
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{}
+	config.blackbox.IpVar = "ansible_host"
+	config.probe, err = loadProbesConfig(cfg, config.blackbox, ConfigNames{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.probe.probes) != 1 || config.probe.probes[0].metricsPath != "/snmp" {
+		t.Fatalf("Expected the probe snmp with the metrics path /snmp, got %v", config.probe.probes)
+	}
+	model := newInventoryModel()
+	model.hosts[1] = &ModelHost{Host: Host{ID: 1, Name: "switch1"}, Variables: map[string]interface{}{
+		"ansible_host": "10.0.0.1",
+		"snmp_config":  []interface{}{map[string]interface{}{"module": "if_mib", "auth": "public_v2"}},
+	}}
+	model.groups[1] = &ModelGroup{Group: Group{ID: 1, Name: "switches"}, HostIDs: []int{1}}
+	content, err := createProbeSD(config, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"labels":{"auth":"public_v2","group":"switches","host":"","ip":"10.0.0.1","job":"snmp","module":"if_mib","team":"network"},"targets":["10.0.0.1"]}]`
+	if string(content) != expected {
+		t.Errorf("Expected %s, got %s", expected, content)
+	}
+	content, err = createProbeScrapeConfigsOutput(config, model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"job_name: snmp", "metrics_path: /snmp", "target_label: __param_auth", "replacement: snmp-exporter:9116"} {
+		if strings.Contains(string(content), expected) == false {
+			t.Errorf("The scrape config does not contain %q:\n%s", expected, content)
+		}
+	}
+}
+
+/// fakeResolver resolves only the given hostnames
+type fakeResolver map[string]bool
+
+/// LookupHost Returns an error for the unknown hostnames
+func (resolver fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if resolver[host] {
+		return []string{"10.0.0.1"}, nil
+	}
+	return nil, fmt.Errorf("no such host")
+}
+
+/// TestValidateTarget Tests the validation of the targets per prober of the module
+func TestValidateTarget(t *testing.T) {
+	config := Config{}
+	config.blackbox.moduleProbers = map[string]string{"ssh_banner": "tcp", "custom": "icmp"}
+	for _, test := range []struct {
+		module string
+		target string
+		valid  bool
+	}{
+		{"http_2xx", "https://web1.example.com/health", true},
+		{"http_2xx", "htps://web1.example.com/", false},
+		{"http_2xx", "web1.example.com/health", true},
+		{"http_2xx", "10.0.0.1", true},
+		{"http_2xx", "web1 example/", false},
+		{"ssh_banner", "web1.example.com:22", true},
+		{"tcp_connect", "web1.example.com", false},
+		{"tcp_connect", "web1.example.com:99999", false},
+		{"tcp_connect", ":22", false},
+		{"tcp_connect", "web1_backup.local:22", true},
+		{"tls_connect", "[2001:db8::1]:443", true},
+		{"icmp", "10.0.0.1", true},
+		{"custom", "web1 example", false},
+		{"dns_udp", "ns1.example.com:53", true},
+		{"dns_udp", "10.0.0.53", true},
+		{"if_mib", "anything goes", true},
+	} {
+		err := validateTarget(config, test.module, test.target)
+		if (err == nil) != test.valid {
+			t.Errorf("Expected the target %s of the module %s to be valid=%v, got %v", test.target, test.module, test.valid, err)
+		}
+	}
+	config.blackbox.resolveTargets = true
+	resolver := targetResolver
+	targetResolver = fakeResolver{"web1.example.com": true}
+	defer func() { targetResolver = resolver }()
+	if err := validateTarget(config, "http_2xx", "https://web1.example.com/"); err != nil {
+		t.Errorf("Expected the resolving hostname to be valid, got %v", err)
+	}
+	if err := validateTarget(config, "http_2xx", "https://web2.example.com/"); err == nil {
+		t.Errorf("Expected an error for the hostname that does not resolve")
+	}
+	if err := validateTarget(config, "icmp", "10.0.0.2"); err != nil {
+		t.Errorf("Expected the IP address not to be resolved, got %v", err)
+	}
+}
+
+/// TestCreateBlackboxHostValidation Tests that the IpVar targets are validated for the prober and the entries
+/// without valid targets are dropped
+func TestCreateBlackboxHostValidation(t *testing.T) {
+	config := Config{}
+	config.blackbox.configName = "blackbox_config"
+	config.blackbox.IpVar = "ansible_host"
+	hostVariables := map[string]interface{}{"ansible_host": "10.0.0.1"}
+	for _, module := range []string{"http_2xx", "icmp"} {
+		blackboxHosts, _ := createBlackboxHost(config, "web", hostVariables, map[string]interface{}{"module": module}, nil)
+		if len(blackboxHosts) != 1 || len(blackboxHosts[0].Targets) != 1 || blackboxHosts[0].Targets[0] != "10.0.0.1" {
+			t.Errorf("Expected the IpVar target of the module %s, got %v", module, blackboxHosts)
+		}
+	}
+	for _, module := range []string{"tcp_connect", "tls_connect"} {
+		blackboxHosts, _ := createBlackboxHost(config, "web", hostVariables, map[string]interface{}{"module": module}, nil)
+		if len(blackboxHosts) != 0 {
+			t.Errorf("Expected no IpVar target without port for the module %s, got %v", module, blackboxHosts)
+		}
+	}
+	entry := map[string]interface{}{"module": "http_2xx", "targets": []interface{}{"htps://web1/", map[string]interface{}{"url": "htps://web2/"}}}
+	blackboxHosts, _ := createBlackboxHost(config, "web", hostVariables, entry, nil)
+	if len(blackboxHosts) != 0 {
+		t.Errorf("Expected no target group when all the targets are invalid, got %v", blackboxHosts)
+	}
+}
+
+/// TestLintModelInvalidTargets Tests that the invalid blackbox targets are reported by lint
+func TestLintModelInvalidTargets(t *testing.T) {
+	config := Config{}
+	config.blackbox.configName = "blackbox_config"
+	model := newInventoryModel()
+	model.hosts[1] = &ModelHost{Host: Host{ID: 1, Name: "web1"}, Variables: map[string]interface{}{
+		"blackbox_config": []interface{}{map[string]interface{}{"module": "http_2xx", "targets": []interface{}{"htps://web1/", "https://web1/"}}},
+	}}
+	model.groups[1] = &ModelGroup{Group: Group{ID: 1, Name: "web"}, HostIDs: []int{1}}
+	var logOutput bytes.Buffer
+	log.SetOutput(&logOutput)
+	problems := lintModel(config, model)
+	log.SetOutput(os.Stderr)
+	if strings.Contains(logOutput.String(), "htps://web1/") {
+		t.Errorf("The collected validation errors should not be logged, got %s", logOutput.String())
+	}
+	if len(problems) != 1 || strings.Contains(problems[0], "htps://web1/") == false {
+		t.Errorf("Expected the invalid target htps://web1/ to be reported, got %v", problems)
+	}
+}
+
+/// getValidationErrorCount Returns the number of the skipped invalid entries of all the AWX variables
+func getValidationErrorCount() float64 {
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		return 0
+	}
+	count := 0.0
+	for _, family := range families {
+		if family.GetName() != "awx_exporter_validation_errors_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			count += metric.GetCounter().GetValue()
+		}
+	}
+	return count
+}
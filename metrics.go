@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gopkg.in/yaml.v2"
+)
+
+/// metricsRegistry contains the self monitoring metrics of the exporter
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	syncDurationSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "awx_exporter_sync_duration_seconds",
+		Help: "The duration of the last generation of the mode in seconds.",
+	}, []string{"mode"})
+	syncFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "awx_exporter_sync_failures_total",
+		Help: "The number of failed generations of the mode.",
+	}, []string{"mode"})
+	lastSuccessTimestampSeconds = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "awx_exporter_last_success_timestamp_seconds",
+		Help: "The unix timestamp of the last successful generation of the mode.",
+	}, []string{"mode"})
+	awxRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "awx_exporter_awx_requests_total",
+		Help: "The number of requests sent to AWX by endpoint and status code.",
+	}, []string{"endpoint", "code"})
+	awxRequestRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "awx_exporter_awx_request_retries_total",
+		Help: "The number of retried requests to AWX by endpoint.",
+	}, []string{"endpoint"})
+	targets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "awx_exporter_targets",
+		Help: "The number of targets generated by mode, job and group.",
+	}, []string{"mode", "job", "group"})
+	validationErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "awx_exporter_validation_errors_total",
+		Help: "The number of invalid AWX variables that were skipped by variable name.",
+	}, []string{"variable"})
+	safetyGuardBlocked = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "awx_exporter_safety_guard_blocked",
+		Help: "Whether the safety guard blocked the last write of the mode.",
+	}, []string{"mode"})
+	webhooksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "awx_exporter_webhooks_total",
+		Help: "The number of received AWX notifications by result.",
+	}, []string{"result"})
+	inventorySyncsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "awx_exporter_inventory_syncs_total",
+		Help: "The number of full and incremental syncs of the inventory model by result.",
+	}, []string{"type", "result"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		syncDurationSeconds,
+		syncFailuresTotal,
+		lastSuccessTimestampSeconds,
+		awxRequestsTotal,
+		awxRequestRetriesTotal,
+		targets,
+		validationErrorsTotal,
+		safetyGuardBlocked,
+		webhooksTotal,
+		inventorySyncsTotal,
+		coverageCollector,
+		inventoryCollector,
+	)
+}
+
+/// idRegexp matches the ids in the AWX paths
+var idRegexp = regexp.MustCompile("/[0-9]+/")
+
+/// getEndpointLabel Returns the AWX path without the prefix, query and ids, so it can be used as label
+func getEndpointLabel(path string) string {
+	path = strings.SplitN(path, "?", 2)[0]
+	path = strings.TrimPrefix(path, "/api/v2/")
+	path = idRegexp.ReplaceAllString("/"+path, "/:id/")
+	return strings.Trim(path, "/")
+}
+
+/// targetLabelSets keeps the label sets of the targets metric per mode, so the vanished ones can be deleted
+var targetLabelSets = struct {
+	sync.Mutex
+	labels map[string][]prometheus.Labels
+}{labels: make(map[string][]prometheus.Labels)}
+
+/// countTargets Returns the number of targets per job and group of the given output
+func countTargets(mode Mode, content []byte) map[[2]string]int {
+	counts := make(map[[2]string]int)
+	var targetGroups []struct {
+		Labels  map[string]string `json:"labels"`
+		Targets []string          `json:"targets"`
+	}
+	if json.Unmarshal(content, &targetGroups) == nil {
+		for _, targetGroup := range targetGroups {
+			counts[[2]string{targetGroup.Labels["job"], targetGroup.Labels["group"]}] += len(targetGroup.Targets)
+		}
+		return counts
+	}
+	var scrapeConfigs ScrapeConfigs
+	if yaml.Unmarshal(content, &scrapeConfigs) == nil {
+		for _, scrapeConfig := range scrapeConfigs.ScrapeConfigs {
+			for _, staticConfig := range scrapeConfig.StaticConfigs {
+				counts[[2]string{scrapeConfig.JobName, staticConfig.Labels["group"]}] += len(staticConfig.Targets)
+			}
+		}
+	}
+	return counts
+}
+
+/// recordTargets Sets the targets metric of the given mode
+func recordTargets(mode Mode, content []byte) {
+	targetLabelSets.Lock()
+	defer targetLabelSets.Unlock()
+	for _, labels := range targetLabelSets.labels[mode.Name] {
+		targets.Delete(labels)
+	}
+	var labelSets []prometheus.Labels
+	for key, count := range countTargets(mode, content) {
+		labels := prometheus.Labels{"mode": mode.Name, "job": key[0], "group": key[1]}
+		targets.With(labels).Set(float64(count))
+		labelSets = append(labelSets, labels)
+	}
+	targetLabelSets.labels[mode.Name] = labelSets
+}
+
+/// runMode Generates the given mode from the source and records the metrics of the generation
+func runMode(config Config, mode Mode, source InventorySource) ([]byte, error) {
+	start := time.Now()
+	content, err := mode.Generate(config, source)
+	syncDurationSeconds.WithLabelValues(mode.Name).Set(time.Since(start).Seconds())
+	if err != nil {
+		syncFailuresTotal.WithLabelValues(mode.Name).Inc()
+		return nil, err
+	}
+	lastSuccessTimestampSeconds.WithLabelValues(mode.Name).SetToCurrentTime()
+	recordTargets(mode, content)
+	return content, nil
+}
+
+/// recordSafetyGuard Sets whether the safety guard blocked the given mode
+func recordSafetyGuard(mode Mode, blocked bool) {
+	value := 0.0
+	if blocked {
+		value = 1
+	}
+	safetyGuardBlocked.WithLabelValues(mode.Name).Set(value)
+}
+
+/// metricsHandler Returns the http handler of the self monitoring metrics
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+/// writeMetricsTextfile Writes the self monitoring metrics for the node_exporter textfile collector
+func writeMetricsTextfile(path string) error {
+	return prometheus.WriteToTextfile(path, metricsRegistry)
+}
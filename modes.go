@@ -0,0 +1,116 @@
+package main
+
+/// Mode is a single output mode of the exporter
+type Mode struct {
+	Name       string
+	Usage      string
+	Extension  string
+	Generate   func(Config, InventorySource) ([]byte, error)
+	OutputFile func(Config) string
+	Summarize  func(Config, []byte) (Summary, error)
+}
+
+/// createAlertManagerOutput Creates the printable Alertmanager config
+func createAlertManagerOutput(config Config, source InventorySource) ([]byte, error) {
+	alertManagerConfig, err := createAlertManagerConfig(config, source)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(alertManagerConfig.String()), nil
+}
+
+/// createScrapeConfigsOutput Creates the printable scrape configs
+func createScrapeConfigsOutput(config Config, source InventorySource) ([]byte, error) {
+	scrapeConfigs, err := createScrapeConfigs(config, source)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(scrapeConfigs.String()), nil
+}
+
+/// createPrometheusFileConfigOutput Creates the printable Prometheus config
+func createPrometheusFileConfigOutput(config Config, source InventorySource) ([]byte, error) {
+	prometheusFileConfig, err := createPrometheusFileConfig(config, source)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(prometheusFileConfig.String()), nil
+}
+
+/// getModes Returns all the output modes of the exporter
+func getModes() []Mode {
+	return []Mode{
+		{
+			Name:       "alertmanager",
+			Extension:  "yml",
+			Usage:      "The Alert Manager mode for the exporter",
+			Generate:   createAlertManagerOutput,
+			Summarize:  summarizeAlertManagerConfig,
+			OutputFile: func(config Config) string { return config.alertmanager.outputFile },
+		},
+		{
+			Name:       "prometheus",
+			Extension:  "json",
+			Usage:      "The Prometheus mode for the exporter",
+			Generate:   createPrometheusSD,
+			Summarize:  summarizeTargets,
+			OutputFile: func(config Config) string { return config.prometheus.outputFile },
+		},
+		{
+			Name:       "blackbox",
+			Extension:  "json",
+			Usage:      "Blackbox mode for the exporter",
+			Generate:   createBlackboxSD,
+			Summarize:  summarizeTargets,
+			OutputFile: func(config Config) string { return config.blackbox.outputFile },
+		},
+		{
+			Name:       "scrape-config",
+			Extension:  "yml",
+			Usage:      "The Prometheus scrape_configs mode for the exporter",
+			Generate:   createScrapeConfigsOutput,
+			Summarize:  summarizeScrapeConfigs,
+			OutputFile: func(config Config) string { return config.prometheus.scrapeConfigOutputFile },
+		},
+		{
+			Name:       "blackbox-scrape-config",
+			Extension:  "yml",
+			Usage:      "The blackbox scrape_configs mode, creates the probe jobs of the blackbox targets",
+			Generate:   createBlackboxScrapeConfigsOutput,
+			Summarize:  summarizeScrapeConfigs,
+			OutputFile: func(config Config) string { return config.blackbox.scrapeConfigOutputFile },
+		},
+		{
+			Name:       "probe",
+			Extension:  "json",
+			Usage:      "The probe mode, creates the targets of the [PROBE.<name>] exporters like snmp or ipmi",
+			Generate:   createProbeSD,
+			Summarize:  summarizeTargets,
+			OutputFile: func(config Config) string { return config.probe.outputFile },
+		},
+		{
+			Name:       "probe-scrape-config",
+			Extension:  "yml",
+			Usage:      "The probe scrape_configs mode, creates one job for each [PROBE.<name>] exporter",
+			Generate:   createProbeScrapeConfigsOutput,
+			Summarize:  summarizeScrapeConfigs,
+			OutputFile: func(config Config) string { return config.probe.scrapeConfigOutputFile },
+		},
+		{
+			Name:       "prometheus-config",
+			Extension:  "yml",
+			Usage:      "The Prometheus config mode, merges the jobs in the existing config",
+			Generate:   createPrometheusFileConfigOutput,
+			Summarize:  summarizePrometheusFileConfig,
+			OutputFile: func(config Config) string { return config.prometheus.configOutputFile },
+		},
+		{
+			Name:       "blackbox-modules",
+			Extension:  "yml",
+			Usage:      "The blackbox exporter config mode, merges the modules in the existing blackbox.yml",
+			Generate:   createBlackboxFileConfigOutput,
+			Summarize:  summarizeBlackboxFileConfig,
+			OutputFile: func(config Config) string { return config.blackbox.modulesOutputFile },
+		},
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/// The exit codes of the one shot mode when the output is written to files
+const (
+	exitUnchanged = 0
+	exitChanged   = 2
+	exitBlocked   = 3
+)
+
+/// writeOutputFile Writes the content atomically to the given path, by writing a temporary file in the
+/// same directory and renaming it. The file is not touched when the content is unchanged.
+func writeOutputFile(path string, content []byte) (bool, error) {
+	existing, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(existing, content) {
+		return false, nil
+	}
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(content)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempFile.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+/// writeModeOutput Writes the content of the given mode to its output file, when the safety guard
+/// does not block it or the write is forced.
+func writeModeOutput(config Config, mode Mode, content []byte, force bool) (bool, error) {
+	path := mode.OutputFile(config)
+	if force == false {
+		previous, err := ioutil.ReadFile(path)
+		if err != nil && os.IsNotExist(err) == false {
+			return false, err
+		}
+		err = checkSafetyGuard(config, mode, previous, content)
+		recordSafetyGuard(mode, errors.Is(err, errSafetyGuard))
+		if err != nil {
+			return false, err
+		}
+	}
+	return writeOutputFile(path, content)
+}
+
+/// sortPrometheusHosts Sorts the prometheus hosts so the output is deterministic
+func sortPrometheusHosts(prometheusHosts []PrometheusHost) {
+	sort.SliceStable(prometheusHosts, func(i, j int) bool {
+		left, right := prometheusHosts[i], prometheusHosts[j]
+		if left.Labels.Job != right.Labels.Job {
+			return left.Labels.Job < right.Labels.Job
+		}
+		if left.Labels.Group != right.Labels.Group {
+			return left.Labels.Group < right.Labels.Group
+		}
+		if left.Labels.Host != right.Labels.Host {
+			return left.Labels.Host < right.Labels.Host
+		}
+		return strings.Join(left.Targets, ",") < strings.Join(right.Targets, ",")
+	})
+}
+
+/// sortBlackboxHosts Sorts the blackbox hosts so the output is deterministic
+func sortBlackboxHosts(blackboxHosts []BlackboxHost) {
+	sort.SliceStable(blackboxHosts, func(i, j int) bool {
+		left, right := blackboxHosts[i], blackboxHosts[j]
+		if left.Labels.Module != right.Labels.Module {
+			return left.Labels.Module < right.Labels.Module
+		}
+		if left.Labels.Group != right.Labels.Group {
+			return left.Labels.Group < right.Labels.Group
+		}
+		if left.Labels.Host != right.Labels.Host {
+			return left.Labels.Host < right.Labels.Host
+		}
+		if left.Labels.Prober != right.Labels.Prober {
+			return left.Labels.Prober < right.Labels.Prober
+		}
+		return strings.Join(left.Targets, ",") < strings.Join(right.Targets, ",")
+	})
+}
+
+/// sortAlertManagerNotifiers Sorts the notifiers by their receiver name so the output is deterministic
+func sortAlertManagerNotifiers(notifiers []AlertManagerEmailNotifier) {
+	sort.SliceStable(notifiers, func(i, j int) bool {
+		return notifiers[i].getReceiverName() < notifiers[j].getReceiverName()
+	})
+}
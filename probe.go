@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+/// ProbeConfig is a [PROBE.<name>] section, a multi target exporter like the snmp or ipmi exporter that
+/// gets the target and its parameters like the blackbox exporter
+type ProbeConfig struct {
+	name            string
+	configName      string
+	exporterAddress string
+	metricsPath     string
+	params          []string
+	labels          map[string]string
+	IpVar           string
+	HostNameVar     string
+}
+
+/// ProbesConfig contains the probe sections and the outputs of the probe modes
+type ProbesConfig struct {
+	probes                 []ProbeConfig
+	fileSDPath             string
+	outputFile             string
+	scrapeConfigOutputFile string
+}
+
+/// loadProbesConfig Returns the [PROBE] section and the [PROBE.<name>] sections of the configuration, the
+/// names of the probes are taken from the given user defined names
+func loadProbesConfig(cfg *ini.File, blackbox BlackboxConfig, names ConfigNames) (ProbesConfig, error) {
+	probesConfig := ProbesConfig{
+		fileSDPath:             cfg.Section("PROBE").Key("FileSDPath").String(),
+		outputFile:             cfg.Section("PROBE").Key("OutputFile").String(),
+		scrapeConfigOutputFile: cfg.Section("PROBE").Key("ScrapeConfigOutputFile").String(),
+	}
+	for _, section := range cfg.Sections() {
+		// The section names are lower case with the insensitive load options
+		if strings.HasPrefix(strings.ToLower(section.Name()), "probe.") == false {
+			continue
+		}
+		name := names.get(section.Name())[len("probe."):]
+		probe := ProbeConfig{
+			name:            name,
+			configName:      section.Key("ConfigName").MustString(name + "_config"),
+			exporterAddress: section.Key("ExporterAddress").String(),
+			metricsPath:     section.Key("MetricsPath").MustString("/" + name),
+			params:          section.Key("Params").Strings(","),
+			labels:          make(map[string]string),
+			IpVar:           section.Key("IpVar").MustString(blackbox.IpVar),
+			HostNameVar:     section.Key("HostNameVar").MustString(blackbox.HostNameVar),
+		}
+		if len(probe.params) == 0 {
+			probe.params = []string{"module"}
+		}
+		if probe.exporterAddress == "" {
+			return probesConfig, fmt.Errorf("The ExporterAddress of the probe %s is not set", name)
+		}
+		for _, label := range section.Key("Labels").Strings(",") {
+			parts := strings.SplitN(label, "=", 2)
+			if len(parts) != 2 || labelNameRegexp.MatchString(parts[0]) == false {
+				return probesConfig, fmt.Errorf("The label %s of the probe %s should be in the name=value format", label, name)
+			}
+			probe.labels[parts[0]] = parts[1]
+		}
+		probesConfig.probes = append(probesConfig.probes, probe)
+	}
+	sort.Slice(probesConfig.probes, func(i, j int) bool {
+		return probesConfig.probes[i].name < probesConfig.probes[j].name
+	})
+	return probesConfig, nil
+}
+
+/// getProbeConfig Returns the configuration with the blackbox settings replaced by the settings of the probe,
+/// so the probe targets are created like the blackbox targets. The group settings of the blackbox are kept.
+func getProbeConfig(config Config, probe ProbeConfig) Config {
+	probeConfig := config
+	probeConfig.blackbox = BlackboxConfig{
+		configName:      probe.configName,
+		IgnoredGroups:   config.blackbox.IgnoredGroups,
+		HostNameVar:     probe.HostNameVar,
+		IpVar:           probe.IpVar,
+		exporterAddress: probe.exporterAddress,
+		fileSDPath:      config.probe.fileSDPath,
+		loadFacts:       config.blackbox.loadFacts,
+		groupStrategy:   config.blackbox.groupStrategy,
+		preferredGroups: config.blackbox.preferredGroups,
+		fallbackGroup:   config.blackbox.fallbackGroup,
+		jobName:         probe.name,
+		scrapeJobs:      blackboxScrapeJobsCombined,
+		metricsPath:     probe.metricsPath,
+		params:          probe.params,
+		labels:          probe.labels,
+		resolveTargets:  config.blackbox.resolveTargets,
+	}
+	return probeConfig
+}
+
+/// createProbeHosts Returns the targets of all the probes
+func createProbeHosts(config Config, source InventorySource) (map[string][]BlackboxHost, error) {
+	probeHosts := make(map[string][]BlackboxHost)
+	for _, probe := range config.probe.probes {
+		blackboxHosts, err := source.BlackboxHosts(getProbeConfig(config, probe))
+		if err != nil {
+			return nil, fmt.Errorf("can not create the targets of the probe %s: %w", probe.name, err)
+		}
+		sortBlackboxHosts(blackboxHosts)
+		probeHosts[probe.name] = blackboxHosts
+	}
+	return probeHosts, nil
+}
+
+/// createProbeSD Creates the service discovery of all the probes, the job label is the name of the probe
+func createProbeSD(config Config, source InventorySource) ([]byte, error) {
+	probeHosts, err := createProbeHosts(config, source)
+	if err != nil {
+		return nil, err
+	}
+	blackboxHosts := []BlackboxHost{}
+	for _, probe := range config.probe.probes {
+		blackboxHosts = append(blackboxHosts, probeHosts[probe.name]...)
+	}
+	return json.Marshal(blackboxHosts)
+}
+
+/// createProbeScrapeConfigsOutput Creates the printable scrape configs with one job for each probe
+func createProbeScrapeConfigsOutput(config Config, source InventorySource) ([]byte, error) {
+	probeHosts, err := createProbeHosts(config, source)
+	if err != nil {
+		return nil, err
+	}
+	scrapeConfigs := ScrapeConfigs{}
+	for _, probe := range config.probe.probes {
+		probeConfig := getProbeConfig(config, probe)
+		scrapeConfigs.ScrapeConfigs = append(scrapeConfigs.ScrapeConfigs, createBlackboxScrapeConfigs(probeConfig, probeHosts[probe.name])...)
+	}
+	return []byte(scrapeConfigs.String()), nil
+}
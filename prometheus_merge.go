@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+/// PrometheusFileConfig is the content of a prometheus.yml, the keys that are not managed are kept as they are
+type PrometheusFileConfig yaml.MapSlice
+
+/// String Returns the yaml representation of the prometheus config
+func (prometheusFileConfig PrometheusFileConfig) String() string {
+	out, err := yaml.Marshal(yaml.MapSlice(prometheusFileConfig))
+	if err != nil {
+		return fmt.Sprintf("<error creating prometheus config string: %s>", err)
+	}
+	return string(out)
+}
+
+/// readPrometheusConfig Reads the prometheus configuration from the source file
+func readPrometheusConfig(config Config) (PrometheusFileConfig, error) {
+	var prometheusFileConfig yaml.MapSlice
+	content, err := ioutil.ReadFile(config.prometheus.sourceFile)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(content, &prometheusFileConfig)
+	if err != nil {
+		return nil, err
+	}
+	return PrometheusFileConfig(prometheusFileConfig), nil
+}
+
+/// toMapSlice Converts the given scrape config to the generic yaml representation
+func toMapSlice(scrapeConfig ScrapeConfig) (yaml.MapSlice, error) {
+	var mapSlice yaml.MapSlice
+	out, err := yaml.Marshal(scrapeConfig)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(out, &mapSlice)
+	return mapSlice, err
+}
+
+/// getJobName Returns the job name of the given generic scrape config
+func getJobName(scrapeConfig interface{}) string {
+	if mapSlice, ok := scrapeConfig.(yaml.MapSlice); ok {
+		for _, item := range mapSlice {
+			if item.Key == "job_name" {
+				return fmt.Sprintf("%v", item.Value)
+			}
+		}
+	}
+	return ""
+}
+
+/// mergeScrapeConfigs Merges the generated scrape configs in the given prometheus config. Only the jobs
+/// with the given prefix are removed, updated or added, all the other jobs and keys are kept as they are.
+func mergeScrapeConfigs(
+	prometheusFileConfig PrometheusFileConfig,
+	jobPrefix string,
+	scrapeConfigs []ScrapeConfig) (PrometheusFileConfig, error) {
+	if jobPrefix == "" {
+		return nil, errors.New("the job prefix can not be empty")
+	}
+	generated := make(map[string]yaml.MapSlice)
+	var generatedNames []string
+	for _, scrapeConfig := range scrapeConfigs {
+		scrapeConfig.JobName = jobPrefix + scrapeConfig.JobName
+		mapSlice, err := toMapSlice(scrapeConfig)
+		if err != nil {
+			return nil, err
+		}
+		generated[scrapeConfig.JobName] = mapSlice
+		generatedNames = append(generatedNames, scrapeConfig.JobName)
+	}
+	scrapeConfigsIndex := -1
+	for idx, item := range prometheusFileConfig {
+		if item.Key == "scrape_configs" {
+			scrapeConfigsIndex = idx
+		}
+	}
+	if scrapeConfigsIndex == -1 {
+		prometheusFileConfig = append(prometheusFileConfig, yaml.MapItem{Key: "scrape_configs"})
+		scrapeConfigsIndex = len(prometheusFileConfig) - 1
+	}
+	var existing []interface{}
+	if value := prometheusFileConfig[scrapeConfigsIndex].Value; value != nil {
+		var ok bool
+		existing, ok = value.([]interface{})
+		if !ok {
+			return nil, errors.New("the scrape_configs of the prometheus config should be a list")
+		}
+	}
+	var merged []interface{}
+	seen := make(map[string]bool)
+	for _, job := range existing {
+		jobName := getJobName(job)
+		if strings.HasPrefix(jobName, jobPrefix) == false {
+			merged = append(merged, job)
+			continue
+		}
+		// Update the existing jobs and remove the not existing ones
+		if generatedJob, ok := generated[jobName]; ok {
+			merged = append(merged, generatedJob)
+			seen[jobName] = true
+		}
+	}
+	// Add the new jobs
+	for _, jobName := range generatedNames {
+		if seen[jobName] == false {
+			merged = append(merged, generated[jobName])
+		}
+	}
+	prometheusFileConfig[scrapeConfigsIndex].Value = merged
+	return prometheusFileConfig, nil
+}
+
+/// createPrometheusFileConfig Creates the Prometheus configuration from an existing config file
+func createPrometheusFileConfig(config Config, source InventorySource) (PrometheusFileConfig, error) {
+	prometheusFileConfig, err := readPrometheusConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("can not read the prometheus config: %w", err)
+	}
+	scrapeConfigs, err := createScrapeConfigs(config, source)
+	if err != nil {
+		return nil, err
+	}
+	prometheusFileConfig, err = mergeScrapeConfigs(prometheusFileConfig, config.prometheus.jobPrefix, scrapeConfigs.ScrapeConfigs)
+	if err != nil {
+		return nil, fmt.Errorf("can not merge the scrape configs: %w", err)
+	}
+	return prometheusFileConfig, nil
+}
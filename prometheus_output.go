@@ -8,16 +8,20 @@ type PrometheusHostLabel struct {
 }
 
 type PrometheusHost struct {
-	Labels  PrometheusHostLabel `json:"labels"`
-	Targets []string            `json:"targets"`
+	Labels  PrometheusHostLabel      `json:"labels"`
+	Targets []string                 `json:"targets"`
+	Scrape  PrometheusScrapeSettings `json:"-"`
 }
 
 type BlackboxHostLabel struct {
-	Group  string `json:"group"`
-	Host   string `json:"host"`
-	IP     string `json:"ip"`
-	Job    string `json:"job"`
-	Module string `json:"module"`
+	Group         string            `json:"group"`
+	Host          string            `json:"host"`
+	IP            string            `json:"ip"`
+	Job           string            `json:"job"`
+	Module        string            `json:"module"`
+	Prober        string            `json:"prober,omitempty"`
+	ProberAddress string            `json:"__prober_address,omitempty"`
+	Extra         map[string]string `json:"-"`
 }
 
 type BlackboxHost struct {
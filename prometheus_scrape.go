@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+/// The scrape jobs of the blackbox targets, one per module or one for all the modules
+const (
+	blackboxScrapeJobsModule   = "module"
+	blackboxScrapeJobsCombined = "combined"
+)
+
+/// PrometheusScrapeSettings contains the per job settings that can be set in the prometheus_config entries
+type PrometheusScrapeSettings struct {
+	Scheme         string
+	MetricsPath    string
+	ScrapeInterval string
+	ScrapeTimeout  string
+}
+
+/// StaticConfig is a single static_configs entry of a scrape job
+type StaticConfig struct {
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
+}
+
+/// FileSDConfig is a single file_sd_configs entry of a scrape job
+type FileSDConfig struct {
+	Files []string `yaml:"files"`
+}
+
+/// RelabelConfig is a single relabel_configs entry of a scrape job
+type RelabelConfig struct {
+	SourceLabels []string `yaml:"source_labels,flow,omitempty"`
+	Regex        string   `yaml:"regex,omitempty"`
+	TargetLabel  string   `yaml:"target_label,omitempty"`
+	Replacement  string   `yaml:"replacement,omitempty"`
+	Action       string   `yaml:"action,omitempty"`
+}
+
+/// ScrapeConfig is a single job of the Prometheus scrape_configs
+type ScrapeConfig struct {
+	JobName        string              `yaml:"job_name"`
+	ScrapeInterval string              `yaml:"scrape_interval,omitempty"`
+	ScrapeTimeout  string              `yaml:"scrape_timeout,omitempty"`
+	MetricsPath    string              `yaml:"metrics_path,omitempty"`
+	Scheme         string              `yaml:"scheme,omitempty"`
+	Params         map[string][]string `yaml:"params,omitempty"`
+	StaticConfigs  []StaticConfig      `yaml:"static_configs,omitempty"`
+	FileSDConfigs  []FileSDConfig      `yaml:"file_sd_configs,omitempty"`
+	RelabelConfigs []RelabelConfig     `yaml:"relabel_configs,omitempty"`
+}
+
+/// ScrapeConfigs is the document that can be included in the prometheus.yml
+type ScrapeConfigs struct {
+	ScrapeConfigs []ScrapeConfig `yaml:"scrape_configs"`
+}
+
+/// getPrometheusScrapeSettings Returns the scrape settings of the given prometheus_config entry
+func getPrometheusScrapeSettings(promSingleNode map[string]interface{}) PrometheusScrapeSettings {
+	settings := PrometheusScrapeSettings{}
+	if scheme, ok := promSingleNode["scheme"]; ok {
+		settings.Scheme = fmt.Sprintf("%v", scheme)
+	}
+	if metricsPath, ok := promSingleNode["metrics_path"]; ok {
+		settings.MetricsPath = fmt.Sprintf("%v", metricsPath)
+	}
+	if scrapeInterval, ok := promSingleNode["scrape_interval"]; ok {
+		settings.ScrapeInterval = fmt.Sprintf("%v", scrapeInterval)
+	}
+	if scrapeTimeout, ok := promSingleNode["scrape_timeout"]; ok {
+		settings.ScrapeTimeout = fmt.Sprintf("%v", scrapeTimeout)
+	}
+	return settings
+}
+
+/// getPrometheusHostLabels Returns the labels of the given host as map
+func getPrometheusHostLabels(labels PrometheusHostLabel) map[string]string {
+	return map[string]string{
+		"group": labels.Group,
+		"host":  labels.Host,
+		"ip":    labels.IP,
+	}
+}
+
+/// getBlackboxHostLabels Returns the labels of the given blackbox host as map, the job label is set by the job
+func getBlackboxHostLabels(labels BlackboxHostLabel) map[string]string {
+	hostLabels := labels.getMap()
+	delete(hostLabels, "job")
+	return hostLabels
+}
+
+/// setTargetsSD Sets the service discovery part of the job, which is either the static configs
+/// or a file_sd reference to the given file that is filtered by the given label. The value is matched literally.
+func setTargetsSD(
+	config Config,
+	scrapeConfig *ScrapeConfig,
+	fileSDPath string,
+	filterLabel string,
+	filterValue string,
+	staticConfigs []StaticConfig) {
+	if config.prometheus.scrapeTargetMode == "file_sd" && fileSDPath != "" {
+		scrapeConfig.FileSDConfigs = []FileSDConfig{{Files: []string{fileSDPath}}}
+		scrapeConfig.RelabelConfigs = append(scrapeConfig.RelabelConfigs, RelabelConfig{
+			SourceLabels: []string{filterLabel},
+			Regex:        regexp.QuoteMeta(filterValue),
+			Action:       "keep",
+		})
+		return
+	}
+	scrapeConfig.StaticConfigs = staticConfigs
+}
+
+/// createPrometheusScrapeConfigs Creates one scrape job for each distinct prometheus_config name
+func createPrometheusScrapeConfigs(config Config, prometheusHosts []PrometheusHost) []ScrapeConfig {
+	jobs := make(map[string]*ScrapeConfig)
+	var staticConfigs = make(map[string][]StaticConfig)
+	for _, prometheusHost := range prometheusHosts {
+		jobName := prometheusHost.Labels.Job
+		if jobName == "" {
+			continue
+		}
+		settings := prometheusHost.Scrape
+		job, ok := jobs[jobName]
+		if !ok {
+			job = &ScrapeConfig{
+				JobName:        jobName,
+				Scheme:         settings.Scheme,
+				MetricsPath:    settings.MetricsPath,
+				ScrapeInterval: settings.ScrapeInterval,
+				ScrapeTimeout:  settings.ScrapeTimeout,
+			}
+			jobs[jobName] = job
+		} else if job.Scheme != settings.Scheme ||
+			job.MetricsPath != settings.MetricsPath ||
+			job.ScrapeInterval != settings.ScrapeInterval ||
+			job.ScrapeTimeout != settings.ScrapeTimeout {
+			log.Printf("The scrape settings of job %s differ in group %s, using the first ones", jobName, prometheusHost.Labels.Group)
+		}
+		if len(prometheusHost.Targets) > 0 {
+			staticConfigs[jobName] = append(staticConfigs[jobName], StaticConfig{
+				Targets: prometheusHost.Targets,
+				Labels:  getPrometheusHostLabels(prometheusHost.Labels),
+			})
+		}
+	}
+	var scrapeConfigs []ScrapeConfig
+	for jobName, job := range jobs {
+		setTargetsSD(config, job, config.prometheus.fileSDPath, "job", jobName, staticConfigs[jobName])
+		scrapeConfigs = append(scrapeConfigs, *job)
+	}
+	sort.Slice(scrapeConfigs, func(i, j int) bool {
+		return scrapeConfigs[i].JobName < scrapeConfigs[j].JobName
+	})
+	return scrapeConfigs
+}
+
+/// getBlackboxRelabelConfigs Returns the relabel configs that send the targets to the blackbox exporter,
+/// without ExporterAddress the targets are only sent to their probers
+func getBlackboxRelabelConfigs(exporterAddress string) []RelabelConfig {
+	relabelConfigs := []RelabelConfig{
+		{SourceLabels: []string{"__address__"}, TargetLabel: "__param_target"},
+		{SourceLabels: []string{"__param_target"}, TargetLabel: "instance"},
+	}
+	if exporterAddress != "" {
+		relabelConfigs = append(relabelConfigs, RelabelConfig{TargetLabel: "__address__", Replacement: exporterAddress})
+	}
+	return relabelConfigs
+}
+
+/// checkBlackboxExporterAddress Returns an error when the ExporterAddress is not set and a target has no prober
+func checkBlackboxExporterAddress(config Config, blackboxHosts []BlackboxHost) error {
+	if config.blackbox.exporterAddress != "" {
+		return nil
+	}
+	for _, blackboxHost := range blackboxHosts {
+		if blackboxHost.Labels.Prober == "" {
+			return fmt.Errorf("the ExporterAddress of the blackbox exporter is not set and the targets of the module %s have no prober", blackboxHost.Labels.Module)
+		}
+	}
+	return nil
+}
+
+/// getBlackboxJobName Returns the job name of the blackbox targets
+func getBlackboxJobName(config Config) string {
+	if config.blackbox.jobName == "" {
+		return "blackbox"
+	}
+	return config.blackbox.jobName
+}
+
+/// getBlackboxMetricsPath Returns the path of the probe endpoint of the exporter
+func getBlackboxMetricsPath(config Config) string {
+	if config.blackbox.metricsPath == "" {
+		return "/probe"
+	}
+	return config.blackbox.metricsPath
+}
+
+/// createBlackboxScrapeConfigs Creates one scrape job for each blackbox module, or a single job for all the
+/// modules with ScrapeJobs=combined which passes the module label as parameter
+func createBlackboxScrapeConfigs(config Config, blackboxHosts []BlackboxHost) []ScrapeConfig {
+	combined := config.blackbox.scrapeJobs == blackboxScrapeJobsCombined
+	jobs := make(map[string]*ScrapeConfig)
+	var staticConfigs = make(map[string][]StaticConfig)
+	for _, blackboxHost := range blackboxHosts {
+		module := blackboxHost.Labels.Module
+		if module == "" && !combined {
+			continue
+		}
+		jobName := fmt.Sprintf("%s_%s", getBlackboxJobName(config), module)
+		if combined {
+			jobName = getBlackboxJobName(config)
+		}
+		if _, ok := jobs[jobName]; !ok {
+			jobs[jobName] = &ScrapeConfig{
+				JobName:     jobName,
+				MetricsPath: getBlackboxMetricsPath(config),
+			}
+			if !combined {
+				jobs[jobName].Params = map[string][]string{"module": {module}}
+			}
+		}
+		if len(blackboxHost.Targets) > 0 {
+			staticConfigs[jobName] = append(staticConfigs[jobName], StaticConfig{
+				Targets: blackboxHost.Targets,
+				Labels:  getBlackboxHostLabels(blackboxHost.Labels),
+			})
+		}
+	}
+	var scrapeConfigs []ScrapeConfig
+	for jobName, job := range jobs {
+		if combined {
+			setTargetsSD(config, job, config.blackbox.fileSDPath, "job", jobName, staticConfigs[jobName])
+			params := config.blackbox.params
+			if len(params) == 0 {
+				params = []string{"module"}
+			}
+			for _, param := range params {
+				job.RelabelConfigs = append(job.RelabelConfigs, RelabelConfig{SourceLabels: []string{param}, TargetLabel: "__param_" + param})
+			}
+		} else {
+			// The blackbox file_sd output carries the same job label for all modules, so it is filtered by module
+			// and the job label of the file is replaced with the name of the module job
+			setTargetsSD(config, job, config.blackbox.fileSDPath, "module", job.Params["module"][0], staticConfigs[jobName])
+			if len(job.FileSDConfigs) > 0 {
+				job.RelabelConfigs = append(job.RelabelConfigs, RelabelConfig{TargetLabel: "job", Replacement: jobName})
+			}
+		}
+		job.RelabelConfigs = append(job.RelabelConfigs, getBlackboxRelabelConfigs(config.blackbox.exporterAddress)...)
+		if len(config.blackbox.probers) > 0 {
+			// The targets of a prober are sent to its blackbox exporter instead of the ExporterAddress
+			job.RelabelConfigs = append(job.RelabelConfigs, RelabelConfig{
+				SourceLabels: []string{"__prober_address"},
+				Regex:        "(.+)",
+				TargetLabel:  "__address__",
+			})
+		}
+		scrapeConfigs = append(scrapeConfigs, *job)
+	}
+	sort.Slice(scrapeConfigs, func(i, j int) bool {
+		return scrapeConfigs[i].JobName < scrapeConfigs[j].JobName
+	})
+	return scrapeConfigs
+}
+
+/// createBlackboxScrapeConfigsOutput Creates the printable scrape configs of the blackbox targets
+func createBlackboxScrapeConfigsOutput(config Config, source InventorySource) ([]byte, error) {
+	blackboxHosts, err := source.BlackboxHosts(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBlackboxExporterAddress(config, blackboxHosts); err != nil {
+		return nil, err
+	}
+	sortBlackboxHosts(blackboxHosts)
+	scrapeConfigs := ScrapeConfigs{ScrapeConfigs: createBlackboxScrapeConfigs(config, blackboxHosts)}
+	return []byte(scrapeConfigs.String()), nil
+}
+
+/// createScrapeConfigs Creates the complete scrape_configs for the prometheus and blackbox targets of the source
+func createScrapeConfigs(config Config, source InventorySource) (ScrapeConfigs, error) {
+	scrapeConfigs := ScrapeConfigs{}
+	prometheusHosts, err := source.PrometheusHosts(config)
+	if err != nil {
+		return scrapeConfigs, err
+	}
+	sortPrometheusHosts(prometheusHosts)
+	scrapeConfigs.ScrapeConfigs = createPrometheusScrapeConfigs(config, prometheusHosts)
+	if config.blackbox.exporterAddress != "" || len(config.blackbox.probers) > 0 {
+		blackboxHosts, err := source.BlackboxHosts(config)
+		if err != nil {
+			return scrapeConfigs, err
+		}
+		if err := checkBlackboxExporterAddress(config, blackboxHosts); err != nil {
+			return scrapeConfigs, err
+		}
+		sortBlackboxHosts(blackboxHosts)
+		scrapeConfigs.ScrapeConfigs = append(scrapeConfigs.ScrapeConfigs, createBlackboxScrapeConfigs(config, blackboxHosts)...)
+	}
+	return scrapeConfigs, nil
+}
+
+/// String Returns the yaml representation of the scrape configs
+func (scrapeConfigs ScrapeConfigs) String() string {
+	out, err := yaml.Marshal(scrapeConfigs)
+	if err != nil {
+		return fmt.Sprintf("<error creating scrape configs string: %s>", err)
+	}
+	return string(out)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	altMgrConfig "github.com/uniwue-rz/awx-exporter/alertmanager/config"
+	"gopkg.in/yaml.v2"
+)
+
+/// errSafetyGuard is returned when the safety guard blocks an output
+var errSafetyGuard = errors.New("blocked by the safety guard")
+
+/// Summary contains the number of targets, receivers or routes per group of an output
+type Summary map[string]int
+
+/// total Returns the number of all the items of the summary
+func (summary Summary) total() int {
+	total := 0
+	for _, count := range summary {
+		total += count
+	}
+	return total
+}
+
+/// summarizeTargets Returns the number of targets per group of the file_sd json output
+func summarizeTargets(config Config, content []byte) (Summary, error) {
+	var targetGroups []struct {
+		Labels  map[string]string `json:"labels"`
+		Targets []string          `json:"targets"`
+	}
+	err := json.Unmarshal(content, &targetGroups)
+	if err != nil {
+		return nil, err
+	}
+	summary := make(Summary)
+	for _, targetGroup := range targetGroups {
+		summary[targetGroup.Labels["group"]] += len(targetGroup.Targets)
+	}
+	return summary, nil
+}
+
+/// summarizeScrapeConfigs Returns the number of static targets per group of the scrape configs
+func summarizeScrapeConfigs(config Config, content []byte) (Summary, error) {
+	return summarizeScrapeJobs(content, "")
+}
+
+/// summarizePrometheusFileConfig Returns the number of static targets per group of the dynamic jobs
+func summarizePrometheusFileConfig(config Config, content []byte) (Summary, error) {
+	return summarizeScrapeJobs(content, config.prometheus.jobPrefix)
+}
+
+/// summarizeBlackboxFileConfig Returns the number of the modules of the blackbox config
+func summarizeBlackboxFileConfig(config Config, content []byte) (Summary, error) {
+	var blackboxFileConfig struct {
+		Modules map[string]interface{} `yaml:"modules"`
+	}
+	err := yaml.Unmarshal(content, &blackboxFileConfig)
+	if err != nil {
+		return nil, err
+	}
+	return Summary{"modules": len(blackboxFileConfig.Modules)}, nil
+}
+
+/// summarizeScrapeJobs Returns the number of static targets per group of the jobs with the given prefix
+func summarizeScrapeJobs(content []byte, jobPrefix string) (Summary, error) {
+	var scrapeConfigs ScrapeConfigs
+	err := yaml.Unmarshal(content, &scrapeConfigs)
+	if err != nil {
+		return nil, err
+	}
+	summary := make(Summary)
+	for _, scrapeConfig := range scrapeConfigs.ScrapeConfigs {
+		if strings.HasPrefix(scrapeConfig.JobName, jobPrefix) == false {
+			continue
+		}
+		for _, staticConfig := range scrapeConfig.StaticConfigs {
+			summary[staticConfig.Labels["group"]] += len(staticConfig.Targets)
+		}
+	}
+	return summary, nil
+}
+
+/// summarizeAlertManagerConfig Returns the number of dynamic receivers and routes per group
+func summarizeAlertManagerConfig(config Config, content []byte) (Summary, error) {
+	dataConfig, err := altMgrConfig.Load(string(content))
+	if err != nil {
+		return nil, err
+	}
+	dynamicReceiverRegexp := regexp.MustCompile("^dynamic-*")
+	receivers := make(map[string]bool)
+	for _, receiver := range dataConfig.Receivers {
+		if dynamicReceiverRegexp.MatchString(receiver.Name) {
+			receivers[receiver.Name] = true
+		}
+	}
+	summary := make(Summary)
+	if dataConfig.Route == nil {
+		return summary, nil
+	}
+	for _, route := range dataConfig.Route.Routes {
+		if dynamicReceiverRegexp.MatchString(route.Receiver) {
+			summary[route.Match["group"]]++
+			if receivers[route.Receiver] {
+				summary[route.Match["group"]]++
+			}
+		}
+	}
+	return summary, nil
+}
+
+/// getVanishedGroups Returns the groups that exist in the previous summary but not in the new one
+func getVanishedGroups(previous Summary, current Summary) []string {
+	var vanished []string
+	for group, count := range previous {
+		if count > 0 && current[group] == 0 {
+			vanished = append(vanished, group)
+		}
+	}
+	sort.Strings(vanished)
+	return vanished
+}
+
+/// checkSafetyGuard Compares the new output with the previous one and returns an error when
+/// the number of items drops by more than the configured percentage or absolute number.
+func checkSafetyGuard(config Config, mode Mode, previous []byte, current []byte) error {
+	if mode.Summarize == nil || len(previous) == 0 {
+		return nil
+	}
+	previousSummary, err := mode.Summarize(config, previous)
+	if err != nil {
+		// The previous output can not be read, so there is nothing to compare with
+		log.Printf("Can not summarize the previous %s output: %v", mode.Name, err)
+		return nil
+	}
+	currentSummary, err := mode.Summarize(config, current)
+	if err != nil {
+		return fmt.Errorf("can not summarize the new %s output: %w", mode.Name, err)
+	}
+	previousTotal, currentTotal := previousSummary.total(), currentSummary.total()
+	drop := previousTotal - currentTotal
+	if drop <= 0 {
+		return nil
+	}
+	dropPercent := float64(drop) * 100 / float64(previousTotal)
+	maxDropAbsolute := config.safetyGuard.maxDropAbsolute
+	maxDropPercent := config.safetyGuard.maxDropPercent
+	if (maxDropAbsolute > 0 && drop > maxDropAbsolute) || (maxDropPercent > 0 && dropPercent > maxDropPercent) {
+		return fmt.Errorf(
+			"%w: the %s output drops from %d to %d items (%.1f%%), the vanished groups are: %s",
+			errSafetyGuard,
+			mode.Name,
+			previousTotal,
+			currentTotal,
+			dropPercent,
+			strings.Join(getVanishedGroups(previousSummary, currentSummary), ","))
+	}
+	return nil
+}
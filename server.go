@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	/// serverReadHeaderTimeout limits the time the clients get to send the request headers
+	serverReadHeaderTimeout = 10 * time.Second
+	/// serverShutdownTimeout limits the time the running requests get to finish on shutdown
+	serverShutdownTimeout = 10 * time.Second
+)
+
+/// createPrometheusSD Creates the Prometheus targets in the file_sd and http_sd format
+func createPrometheusSD(config Config, source InventorySource) ([]byte, error) {
+	prometheusHosts, err := source.PrometheusHosts(config)
+	if err != nil {
+		return nil, err
+	}
+	sortPrometheusHosts(prometheusHosts)
+	return json.Marshal(prometheusHosts)
+}
+
+/// createBlackboxSD Creates the blackbox targets in the file_sd and http_sd format
+func createBlackboxSD(config Config, source InventorySource) ([]byte, error) {
+	blackboxHosts, err := source.BlackboxHosts(config)
+	if err != nil {
+		return nil, err
+	}
+	// With a managed blackbox.yml the targets with undefined modules would fail in the blackbox exporter
+	if config.blackbox.modulesSourceFile != "" {
+		blackboxFileConfig, err := loadBlackboxFileConfig(config, source)
+		if err != nil {
+			return nil, err
+		}
+		err = checkBlackboxModules(blackboxFileConfig, blackboxHosts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sortBlackboxHosts(blackboxHosts)
+	return json.Marshal(blackboxHosts)
+}
+
+/// SDCacheEntry is the last successful result of a service discovery endpoint
+type SDCacheEntry struct {
+	Content   []byte
+	ETag      string
+	Updated   time.Time
+	LastError error
+}
+
+/// SDCache holds the service discovery results that are served by the server
+type SDCache struct {
+	mutex        sync.RWMutex
+	refreshMutex sync.Mutex
+	entries      map[string]*SDCacheEntry
+	modes        map[string]Mode
+	syncer       *InventorySyncer
+}
+
+/// newSDCache Creates the cache with the service discovery endpoints
+func newSDCache() *SDCache {
+	cache := &SDCache{
+		entries: make(map[string]*SDCacheEntry),
+		modes:   make(map[string]Mode),
+		syncer:  newInventorySyncer(),
+	}
+	for _, mode := range getModes() {
+		if mode.Name == "prometheus" || mode.Name == "blackbox" || mode.Name == "probe" {
+			cache.modes[mode.Name] = mode
+		}
+	}
+	return cache
+}
+
+/// getETag Returns the ETag of the given content
+func getETag(content []byte) string {
+	return fmt.Sprintf("\"%x\"", sha256.Sum256(content))
+}
+
+/// refresh Regenerates all the entries
+func (cache *SDCache) refresh(config Config) {
+	var names []string
+	for name := range cache.modes {
+		names = append(names, name)
+	}
+	cache.refreshModes(config, names)
+}
+
+/// refreshModes Regenerates the entries of the given modes, on errors or when the safety guard blocks
+/// the new content the last successful content is kept
+func (cache *SDCache) refreshModes(config Config, names []string) {
+	cache.refreshMutex.Lock()
+	defer cache.refreshMutex.Unlock()
+	source := cache.syncer.source(config)
+	refreshInventoryMetrics(config, source)
+	refreshCoverage(config, source)
+	for _, name := range names {
+		mode, ok := cache.modes[name]
+		if !ok {
+			continue
+		}
+		content, err := runMode(config, mode, source)
+		cache.mutex.Lock()
+		entry, ok := cache.entries[name]
+		if !ok {
+			entry = &SDCacheEntry{}
+			cache.entries[name] = entry
+		}
+		if err == nil {
+			err = checkSafetyGuard(config, mode, entry.Content, content)
+			recordSafetyGuard(mode, errors.Is(err, errSafetyGuard))
+		}
+		if err != nil {
+			log.Printf("Error refreshing the %s targets, serving the stale ones: %v", name, err)
+			entry.LastError = err
+		} else {
+			entry.Content = content
+			entry.ETag = getETag(content)
+			entry.Updated = time.Now()
+			entry.LastError = nil
+			if err := saveState(config, name, content); err != nil {
+				log.Printf("Error saving the state of the %s targets: %v", name, err)
+			}
+		}
+		cache.mutex.Unlock()
+	}
+}
+
+/// loadStates Loads the last known good results from the state directory, so they can be served
+/// even when AWX is not reachable on start.
+func (cache *SDCache) loadStates(config Config) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for name := range cache.modes {
+		content, updated, err := loadState(config, name)
+		if err != nil {
+			continue
+		}
+		cache.entries[name] = &SDCacheEntry{Content: content, ETag: getETag(content), Updated: updated}
+	}
+}
+
+/// SDStatus is the status of a single service discovery endpoint
+type SDStatus struct {
+	Updated    time.Time `json:"updated"`
+	AgeSeconds float64   `json:"age_seconds"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+/// statusHandler Serves the age and the last error of all the endpoints
+func (cache *SDCache) statusHandler(w http.ResponseWriter, r *http.Request) {
+	status := make(map[string]SDStatus)
+	cache.mutex.RLock()
+	for name, entry := range cache.entries {
+		sdStatus := SDStatus{Updated: entry.Updated, AgeSeconds: time.Since(entry.Updated).Seconds()}
+		if entry.LastError != nil {
+			sdStatus.LastError = entry.LastError.Error()
+		}
+		status[name] = sdStatus
+	}
+	cache.mutex.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+/// get Returns a copy of the entry with the given name
+func (cache *SDCache) get(name string) (SDCacheEntry, bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	entry, ok := cache.entries[name]
+	if !ok || entry.Content == nil {
+		return SDCacheEntry{}, false
+	}
+	return *entry, true
+}
+
+/// handler Returns the http handler that serves the entry with the given name
+func (cache *SDCache) handler(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry, ok := cache.get(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("The %s targets are not available yet", name), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", entry.ETag)
+		w.Header().Set("Last-Modified", entry.Updated.UTC().Format(http.TimeFormat))
+		w.Header().Set("X-Data-Age", fmt.Sprintf("%.0f", time.Since(entry.Updated).Seconds()))
+		if r.Header.Get("If-None-Match") == entry.ETag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(entry.Content)
+	}
+}
+
+/// runRefresh Refreshes the cache in the given interval
+func (cache *SDCache) runRefresh(config Config, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		cache.refresh(config)
+	}
+}
+
+/// serve Runs the http service discovery server
+func serve(args []string) int {
+	flags := newFlagSet("serve", "Runs the http service discovery server")
+	var configPath string
+	var overrides ConfigOverrides
+	addConfigFlags(flags, &configPath, &overrides)
+	listenAddress := flags.String("listen-address", "", "The address the server listens on, overrides the config")
+	if exitCode, ok := parseFlags(flags, args); !ok {
+		return exitCode
+	}
+	config, err := loadConfiguration(configPath, overrides)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *listenAddress != "" {
+		config.server.listenAddress = *listenAddress
+	}
+	if (config.server.tlsCertFile == "") != (config.server.tlsKeyFile == "") {
+		fmt.Fprintln(os.Stderr, "The TLSCertFile and the TLSKeyFile of the server should be set together")
+		return 2
+	}
+	cache := newSDCache()
+	cache.loadStates(config)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sd/prometheus", cache.handler("prometheus"))
+	mux.HandleFunc("/sd/blackbox", cache.handler("blackbox"))
+	mux.HandleFunc("/sd/probe", cache.handler("probe"))
+	mux.HandleFunc("/sd/status", cache.statusHandler)
+	mux.Handle("/metrics", metricsHandler())
+	if config.webhook.secret != "" {
+		mux.Handle("/hooks/awx", newWebhookHandler(config, cache))
+	}
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: serverReadHeaderTimeout}
+	listener, err := net.Listen("tcp", config.server.listenAddress)
+	if err != nil {
+		log.Printf("Error running the server %v", err)
+		return 1
+	}
+	log.Printf("Listening on %s", config.server.listenAddress)
+	// Until the first refresh is done the saved states are served and the other modes answer with 503
+	go func() {
+		cache.refresh(config)
+		cache.runRefresh(config, config.server.refreshInterval)
+	}()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	return runServer(config, server, listener, signals)
+}
+
+/// runServer Serves the requests of the listener until a signal is received, then the server is shut down
+/// and the running requests are finished
+func runServer(config Config, server *http.Server, listener net.Listener, signals <-chan os.Signal) int {
+	stopped := make(chan error, 1)
+	go func() {
+		sig := <-signals
+		log.Printf("Received %s, stopping", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+		stopped <- server.Shutdown(ctx)
+	}()
+	var err error
+	if config.server.tlsCertFile != "" {
+		err = server.ServeTLS(listener, config.server.tlsCertFile, config.server.tlsKeyFile)
+	} else {
+		err = server.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = <-stopped
+		if err == nil {
+			return 0
+		}
+	}
+	log.Printf("Error running the server %v", err)
+	return 1
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/// getStateFile Returns the path of the state file of the given mode
+func getStateFile(config Config, modeName string) string {
+	return filepath.Join(config.state.directory, modeName+".state")
+}
+
+/// saveState Persists the last successful result of the given mode in the state directory
+func saveState(config Config, modeName string, content []byte) error {
+	if config.state.directory == "" {
+		return nil
+	}
+	err := os.MkdirAll(config.state.directory, 0755)
+	if err != nil {
+		return err
+	}
+	_, err = writeOutputFile(getStateFile(config, modeName), content)
+	if err != nil {
+		return err
+	}
+	// The modification time is used as the age of the data, so it is updated even when nothing has changed
+	now := time.Now()
+	return os.Chtimes(getStateFile(config, modeName), now, now)
+}
+
+/// loadState Returns the last successful result of the given mode and the time it was created
+func loadState(config Config, modeName string) ([]byte, time.Time, error) {
+	if config.state.directory == "" {
+		return nil, time.Time{}, os.ErrNotExist
+	}
+	stateFile := getStateFile(config, modeName)
+	info, err := os.Stat(stateFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	content, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return content, info.ModTime(), nil
+}
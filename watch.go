@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+/// checkOutputFiles Checks that all the given modes have an output file
+func checkOutputFiles(config Config, modes []Mode) error {
+	for _, mode := range modes {
+		if mode.OutputFile(config) == "" {
+			return fmt.Errorf("the %s mode has no output file in the configuration", mode.Name)
+		}
+	}
+	return nil
+}
+
+/// regenerate Generates the given modes and writes them to their output files. When a mode can not
+/// be generated the last known good result of the state directory or the existing file is kept.
+func regenerate(config Config, modes []Mode, syncer *InventorySyncer) {
+	source := syncer.source(config)
+	refreshInventoryMetrics(config, source)
+	refreshCoverage(config, source)
+	for _, mode := range modes {
+		content, err := runMode(config, mode, source)
+		if err != nil {
+			var updated time.Time
+			var stateErr error
+			content, updated, stateErr = loadState(config, mode.Name)
+			if stateErr != nil {
+				log.Printf("Error generating the %s mode, keeping the existing file: %v", mode.Name, err)
+				continue
+			}
+			log.Printf(
+				"Error generating the %s mode, using the last known good result from %s (%s old): %v",
+				mode.Name,
+				updated.Format(time.RFC3339),
+				time.Since(updated).Round(time.Second),
+				err)
+		}
+		generated := err == nil
+		changed, err := writeModeOutput(config, mode, content, false)
+		if err != nil {
+			log.Printf("Error writing the %s mode: %v", mode.Name, err)
+			continue
+		}
+		if changed {
+			log.Printf("The %s mode has changed and was written to %s", mode.Name, mode.OutputFile(config))
+		}
+		// Only the content that passed the safety guard becomes the last known good state
+		if generated {
+			if err := saveState(config, mode.Name, content); err != nil {
+				log.Printf("Error saving the state of the %s mode: %v", mode.Name, err)
+			}
+		}
+	}
+}
+
+/// reloadConfiguration Returns the configuration from the given path with the given overrides and the modes
+/// that the given function selects for it, which all need an output file
+func reloadConfiguration(configPath string, overrides ConfigOverrides, selectModes func(Config) []Mode) (Config, []Mode, error) {
+	config, err := loadConfiguration(configPath, overrides)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	modes := selectModes(config)
+	err = checkOutputFiles(config, modes)
+	if err != nil {
+		return Config{}, nil, err
+	}
+	return config, modes, nil
+}
+
+/// getModeNames Returns the names of the given modes
+func getModeNames(modes []Mode) []string {
+	var names []string
+	for _, mode := range modes {
+		names = append(names, mode.Name)
+	}
+	return names
+}
+
+/// watch Regenerates the given modes in the given interval until the process is terminated.
+/// SIGHUP reloads the configuration from the given path with the given overrides and selects the modes
+/// again with the given function. When the metrics address is set the exporter metrics are served on it.
+func watch(
+	configPath string,
+	overrides ConfigOverrides,
+	config Config,
+	modes []Mode,
+	selectModes func(Config) []Mode,
+	interval time.Duration,
+	metricsAddress string) {
+	if metricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metricsHandler())
+		go func() {
+			log.Fatal("Error running the metrics endpoint ", http.ListenAndServe(metricsAddress, mux))
+		}()
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	syncer := newInventorySyncer()
+	regenerate(config, modes, syncer)
+	for {
+		select {
+		case <-ticker.C:
+			regenerate(config, modes, syncer)
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				log.Printf("Received %s, stopping", sig)
+				return
+			}
+			newConfig, newModes, err := reloadConfiguration(configPath, overrides, selectModes)
+			if err != nil {
+				log.Printf("Can not reload the configuration, keeping the current one: %v", err)
+				continue
+			}
+			log.Printf("Reloaded the configuration from %s with the modes %s", configPath, strings.Join(getModeNames(newModes), ", "))
+			config = newConfig
+			modes = newModes
+			regenerate(config, modes, syncer)
+		}
+	}
+}
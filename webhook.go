@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/// WebhookHandler triggers the regeneration of the modes on AWX notifications. The notifications that
+/// arrive during the debounce time are combined in a single regeneration.
+type WebhookHandler struct {
+	config  Config
+	cache   *SDCache
+	mutex   sync.Mutex
+	timer   *time.Timer
+	pending map[string]bool
+}
+
+/// newWebhookHandler Creates the webhook handler for the given cache
+func newWebhookHandler(config Config, cache *SDCache) *WebhookHandler {
+	return &WebhookHandler{
+		config:  config,
+		cache:   cache,
+		pending: make(map[string]bool),
+	}
+}
+
+/// validateWebhookRequest Checks the HMAC signature of the body, when the request has one, or the shared secret
+func validateWebhookRequest(secret string, header http.Header, body []byte) bool {
+	if signature := header.Get("X-Hub-Signature-256"); signature != "" {
+		expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal(mac.Sum(nil), expected)
+	}
+	token := header.Get("X-Webhook-Secret")
+	if token == "" {
+		token = strings.TrimPrefix(header.Get("Authorization"), "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+}
+
+/// WebhookPayload contains the fields of the AWX notification that tell which modes are affected. The type is
+/// only sent by a custom notification body, the jobs of the default body are recognized by their playbook.
+type WebhookPayload struct {
+	Type     string `json:"type"`
+	Playbook string `json:"playbook"`
+}
+
+/// getPayloadType Returns the type of the AWX job of the notification or an empty string when it is unknown
+func getPayloadType(body []byte) string {
+	var payload WebhookPayload
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+	if payload.Type != "" {
+		return payload.Type
+	}
+	if payload.Playbook != "" {
+		return "job"
+	}
+	return ""
+}
+
+/// getAffectedModes Returns the modes affected by the AWX job of the notification. The playbook runs only
+/// change the facts, which are used by the blackbox and probe targets with LoadFacts. The project updates
+/// and system jobs do not change the inventory. The inventory updates and the unknown jobs affect all modes.
+func (handler *WebhookHandler) getAffectedModes(body []byte) []string {
+	var names []string
+	switch getPayloadType(body) {
+	case "job", "ad_hoc_command":
+		if handler.config.blackbox.loadFacts {
+			for _, name := range []string{"blackbox", "probe"} {
+				if _, ok := handler.cache.modes[name]; ok {
+					names = append(names, name)
+				}
+			}
+		}
+	case "project_update", "system_job":
+	default:
+		for name := range handler.cache.modes {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+/// ServeHTTP implements the http.Handler interface
+func (handler *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		webhooksTotal.WithLabelValues("invalid").Inc()
+		http.Error(w, "The body can not be read", http.StatusBadRequest)
+		return
+	}
+	if validateWebhookRequest(handler.config.webhook.secret, r.Header, body) == false {
+		webhooksTotal.WithLabelValues("unauthorized").Inc()
+		http.Error(w, "The secret or signature is not valid", http.StatusUnauthorized)
+		return
+	}
+	var names []string
+	if modes := r.URL.Query().Get("modes"); modes != "" {
+		for _, name := range strings.Split(modes, ",") {
+			if _, ok := handler.cache.modes[name]; !ok {
+				webhooksTotal.WithLabelValues("invalid").Inc()
+				http.Error(w, "Unknown mode "+name, http.StatusBadRequest)
+				return
+			}
+			names = append(names, name)
+		}
+	} else {
+		names = handler.getAffectedModes(body)
+	}
+	if len(names) == 0 {
+		webhooksTotal.WithLabelValues("ignored").Inc()
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	webhooksTotal.WithLabelValues("accepted").Inc()
+	handler.schedule(names)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+/// schedule Adds the given modes to the pending ones and starts the debounce timer
+func (handler *WebhookHandler) schedule(names []string) {
+	handler.mutex.Lock()
+	defer handler.mutex.Unlock()
+	for _, name := range names {
+		handler.pending[name] = true
+	}
+	if handler.timer == nil {
+		handler.timer = time.AfterFunc(handler.config.webhook.debounce, handler.fire)
+	}
+}
+
+/// fire Regenerates all the pending modes
+func (handler *WebhookHandler) fire() {
+	handler.mutex.Lock()
+	var names []string
+	for name := range handler.pending {
+		names = append(names, name)
+	}
+	handler.pending = make(map[string]bool)
+	handler.timer = nil
+	handler.mutex.Unlock()
+	sort.Strings(names)
+	log.Printf("Regenerating %s after AWX notifications", strings.Join(names, ","))
+	handler.cache.refreshModes(handler.config, names)
+}